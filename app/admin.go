@@ -0,0 +1,57 @@
+package app
+
+import (
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/validation"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// BootstrapAdmin creates the admin account named by the ADMIN_EMAIL and
+// ADMIN_PASSWORD settings unless a user with that email already exists. It
+// does nothing when either setting is empty, and refuses to start when the
+// email belongs to a user who isn't an admin.
+func (a *App) BootstrapAdmin(ctx context.Context) error {
+	email, password := a.Config.AdminEmail, a.Config.AdminPassword
+	if email == "" || password == "" {
+		return nil
+	}
+	users := a.Repositories.User.UsePrimary()
+
+	existing, err := users.GetUserByEmail(ctx, email)
+	if err == nil {
+		if existing.Role != "admin" {
+			return fmt.Errorf("ADMIN_EMAIL %s belongs to a %s account", email, existing.Role)
+		}
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if !validation.IsPassword(password) {
+		return errors.New("ADMIN_PASSWORD must be 8 to 72 characters long and contain an upper case letter, a lower case letter and a digit")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	err = users.CreateUser(ctx, &models.User{
+		UserId:   "user_" + time.Now().Format("20060102150405"),
+		Name:     "Admin",
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     "admin",
+		Status:   "active",
+	})
+	if err != nil {
+		return err
+	}
+	a.Logger.Info("Created admin account", "email", email)
+	return nil
+}
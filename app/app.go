@@ -0,0 +1,163 @@
+package app
+
+import (
+	"backend-hanssen-hilman/config"
+	"backend-hanssen-hilman/controllers"
+	"backend-hanssen-hilman/jobs"
+	"backend-hanssen-hilman/metrics"
+	"backend-hanssen-hilman/notify"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/routes"
+	"backend-hanssen-hilman/routes/middleware"
+	"backend-hanssen-hilman/search"
+	"backend-hanssen-hilman/storage"
+	"backend-hanssen-hilman/tracing"
+	"backend-hanssen-hilman/util"
+	"backend-hanssen-hilman/validation"
+	"context"
+
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Repositories groups the data access layer used by the controllers.
+type Repositories struct {
+	User         repositories.UserRepository
+	Product      repositories.ProductRepository
+	Transaction  repositories.TransactionRepository
+	Category     repositories.CategoryRepository
+	Variant      repositories.VariantRepository
+	Image        repositories.ImageRepository
+	ImportJob    repositories.ImportJobRepository
+	Inventory    repositories.InventoryRepository
+	Notification repositories.NotificationRepository
+	JobCursor    repositories.JobCursorRepository
+	Review       repositories.ReviewRepository
+	Wishlist     repositories.WishlistRepository
+}
+
+// NewRepositories builds the GORM-backed repositories on top of db.
+func NewRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		User:         repositories.NewUserRepository(db),
+		Product:      repositories.NewProductRepository(db),
+		Transaction:  repositories.NewTransactionRepository(db),
+		Category:     repositories.NewCategoryRepository(db),
+		Variant:      repositories.NewVariantRepository(db),
+		Image:        repositories.NewImageRepository(db),
+		ImportJob:    repositories.NewImportJobRepository(db),
+		Inventory:    repositories.NewInventoryRepository(db),
+		Notification: repositories.NewNotificationRepository(db),
+		JobCursor:    repositories.NewJobCursorRepository(db),
+		Review:       repositories.NewReviewRepository(db),
+		Wishlist:     repositories.NewWishlistRepository(db),
+	}
+}
+
+// App owns every dependency of the HTTP server. Nothing is read from
+// package-level state, so several independent instances can coexist.
+type App struct {
+	Config       *config.Config
+	DB           *gorm.DB
+	Logger       *slog.Logger
+	Metrics      *metrics.Metrics
+	Tracing      *tracing.Tracing
+	Store        storage.BlobStore
+	Search       search.SearchIndex
+	Notifier     notify.Notifier
+	Repositories *Repositories
+	Controllers  *routes.Controllers
+	Router       *gin.Engine
+}
+
+// New wires an App with repositories backed by db.
+func New(cfg *config.Config, db *gorm.DB, logger *slog.Logger) (*App, error) {
+	return NewWithRepositories(cfg, db, logger, NewRepositories(db))
+}
+
+// NewWithRepositories wires an App around the given repositories, which lets
+// callers substitute individual repositories (for example with mocks).
+func NewWithRepositories(cfg *config.Config, db *gorm.DB, logger *slog.Logger, repos *Repositories) (*App, error) {
+	if err := validation.Setup(); err != nil {
+		return nil, err
+	}
+
+	m := metrics.New()
+	if err := m.InstrumentDB(db, cfg.Database.DBName); err != nil {
+		return nil, err
+	}
+
+	t, err := tracing.New(context.Background(), cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.InstrumentDB(db); err != nil {
+		return nil, err
+	}
+
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	index := search.NewInvertedIndex()
+	cursors := util.NewCursorSigner(cfg.CursorSecret)
+	importer := jobs.NewProductImporter(repos.Product, repos.Category, repos.ImportJob, index)
+	notifier := notify.New(cfg.Notify, repos.User, repos.Notification)
+
+	ctrls := &routes.Controllers{
+		Metrics:      m.Handler(),
+		Health:       controllers.NewHealthController(db, cfg.ReadinessTimeout),
+		User:         controllers.NewUserController(repos.User, cfg.JWTSecret, m),
+		Product:      controllers.NewProductController(repos.Product, repos.Category, store, index, cursors),
+		Image:        controllers.NewImageController(repos.Product, repos.Image, store, cfg.Storage.MaxImageBytes, cfg.Storage.ThumbnailSize),
+		Bulk:         controllers.NewBulkController(repos.Product, repos.ImportJob, importer, cfg.ImportMaxBytes),
+		Inventory:    controllers.NewInventoryController(repos.Product, repos.Inventory),
+		Notification: controllers.NewNotificationController(repos.Notification, repos.Product),
+		Review:       controllers.NewReviewController(repos.Review, repos.Product),
+		Wishlist:     controllers.NewWishlistController(repos.Wishlist, repos.Product, repos.Transaction, cfg.ReservationTTL, cfg.Database.ReadYourWritesWindow, m),
+		Category:     controllers.NewCategoryController(repos.Category),
+		Variant:      controllers.NewVariantController(repos.Product, repos.Variant),
+		Transaction:  controllers.NewTransactionController(repos.Transaction, repos.Product, cfg.ReservationTTL, cfg.Database.ReadYourWritesWindow, m, cursors),
+	}
+
+	if local, ok := store.(*storage.LocalStore); ok {
+		ctrls.Media = local.Handler()
+	}
+
+	router := gin.New()
+	// Tracing comes first so every other middleware runs inside the span.
+	router.Use(t.Middleware())
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestLogger(logger))
+	router.Use(middleware.ErrorHandler(logger, cfg.ProblemJSON))
+	router.Use(middleware.Recovery(logger))
+	router.Use(m.Middleware())
+	router.Use(middleware.Timeout(cfg.QueryTimeout, cfg.QueryTimeouts))
+	routes.SetupRoutes(router, ctrls, cfg.JWTSecret)
+
+	return &App{
+		Config:       cfg,
+		DB:           db,
+		Logger:       logger,
+		Metrics:      m,
+		Tracing:      t,
+		Store:        store,
+		Search:       index,
+		Notifier:     notifier,
+		Repositories: repos,
+		Controllers:  ctrls,
+		Router:       router,
+	}, nil
+}
+
+// Run starts the HTTP server on the configured port.
+func (a *App) Run() error {
+	return a.Router.Run(":" + a.Config.Port)
+}
+
+// Shutdown flushes telemetry that is still buffered.
+func (a *App) Shutdown(ctx context.Context) error {
+	return a.Tracing.Shutdown(ctx)
+}
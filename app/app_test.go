@@ -0,0 +1,329 @@
+package app
+
+import (
+	"backend-hanssen-hilman/config"
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/metrics"
+	"backend-hanssen-hilman/migrations"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/routes/middleware"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm/logger"
+)
+
+// newTestApp starts an App on a fresh SQLite file, so the tests need neither
+// a database server nor network access. Spans go to exporter when it is set.
+func newTestApp(t *testing.T, exporter *tracetest.InMemoryExporter) *App {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_NAME", filepath.Join(t.TempDir(), "shop.db"))
+	t.Setenv("DB_REPLICAS", "")
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("STORAGE_BACKEND", "")
+	t.Setenv("STORAGE_LOCAL_DIR", t.TempDir())
+	t.Setenv("TRACING_EXPORTER", "")
+
+	cfg := config.Load()
+	if exporter != nil {
+		cfg.Tracing.SpanExporter = exporter
+	}
+
+	db, err := database.Open(cfg.Database, logger.Discard)
+	if err != nil {
+		t.Fatalf("database.Open: %v", err)
+	}
+	migrations.Migrate(db)
+
+	a, err := New(cfg, db, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := a.Shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return a
+}
+
+// serve sends one request through the router with its full middleware chain.
+func serve(a *App, method, path, token, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNew(t *testing.T) {
+	a := newTestApp(t, nil)
+
+	if a.Config == nil || a.DB == nil || a.Logger == nil || a.Metrics == nil || a.Tracing == nil ||
+		a.Store == nil || a.Search == nil || a.Notifier == nil || a.Repositories == nil ||
+		a.Controllers == nil || a.Router == nil {
+		t.Fatalf("New left a dependency unset: %+v", a)
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if rec := serve(a, http.MethodGet, path, "", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200: %s", path, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestNewInstancesAreIndependent(t *testing.T) {
+	first := newTestApp(t, nil)
+	second := newTestApp(t, nil)
+
+	signUp(t, first, "merchant")
+
+	rec := serve(second, http.MethodPost, "/api/v1/users/login", "", `{"email":"merchant@example.com","password":"Passw0rd"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("login on the other instance = %d, want 401: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRequestThroughStack(t *testing.T) {
+	a := newTestApp(t, nil)
+
+	merchant := signUp(t, a, "merchant")
+	customer := signUp(t, a, "customer")
+
+	rec := serve(a, http.MethodPost, "/api/v1/product/merchant/", merchant,
+		`{"name":"Red Shirt","description":"100% cotton","price":60000,"quantity":5}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create product = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(a, http.MethodGet, "/api/v1/products/?name=shirt", customer, "", middleware.RequestIDHeader, "req-1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list products = %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get(middleware.RequestIDHeader); got != "req-1" {
+		t.Errorf("%s = %q, want the caller's ID", middleware.RequestIDHeader, got)
+	}
+	if !strings.Contains(rec.Body.String(), `"name":"Red Shirt"`) {
+		t.Errorf("list products did not return the new product: %s", rec.Body)
+	}
+
+	// Errors are rendered by the error middleware, with the request ID.
+	rec = serve(a, http.MethodGet, "/api/v1/products/", "", "", middleware.RequestIDHeader, "req-2")
+	var envelope struct {
+		Error struct {
+			Code      string `json:"code"`
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil || rec.Code != http.StatusUnauthorized ||
+		envelope.Error.Code == "" || envelope.Error.RequestID != "req-2" {
+		t.Errorf("unauthenticated list = %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// signUp registers a user with the role and returns their token.
+func signUp(t *testing.T, a *App, role string) string {
+	t.Helper()
+	email := role + "@example.com"
+	rec := serve(a, http.MethodPost, "/api/v1/users/register", "",
+		`{"name":"Test","email":"`+email+`","password":"Passw0rd","role":"`+role+`"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register %s = %d: %s", role, rec.Code, rec.Body)
+	}
+
+	return logIn(t, a, email, "Passw0rd")
+}
+
+// logIn returns the token of the user with the email.
+func logIn(t *testing.T, a *App, email, password string) string {
+	t.Helper()
+	rec := serve(a, http.MethodPost, "/api/v1/users/login", "", `{"email":"`+email+`","password":"`+password+`"}`)
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &login); rec.Code != http.StatusOK || err != nil || login.Token == "" {
+		t.Fatalf("login %s = %d: %s", email, rec.Code, rec.Body)
+	}
+	return login.Token
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	a := newTestApp(t, nil)
+	ctx := context.Background()
+	customer := signUp(t, a, "customer")
+
+	if err := a.BootstrapAdmin(ctx); err != nil {
+		t.Fatalf("BootstrapAdmin without settings: %v", err)
+	}
+
+	a.Config.AdminEmail, a.Config.AdminPassword = "admin@example.com", "password"
+	if err := a.BootstrapAdmin(ctx); err == nil {
+		t.Error("BootstrapAdmin accepted a weak password")
+	}
+
+	a.Config.AdminPassword = "Adm1nPass"
+	for range 2 {
+		if err := a.BootstrapAdmin(ctx); err != nil {
+			t.Fatalf("BootstrapAdmin: %v", err)
+		}
+	}
+	admin := logIn(t, a, "admin@example.com", "Adm1nPass")
+
+	const category = `{"name":"Shirts","slug":"shirts"}`
+	if rec := serve(a, http.MethodPost, "/api/v1/admin/categories/", customer, category); rec.Code != http.StatusForbidden {
+		t.Errorf("create category as customer = %d, want 403: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(a, http.MethodPost, "/api/v1/admin/categories/", admin, category); rec.Code != http.StatusCreated {
+		t.Errorf("create category as admin = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(a, http.MethodGet, "/api/v1/admin/reviews/flagged", admin, ""); rec.Code != http.StatusOK {
+		t.Errorf("list flagged reviews as admin = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	// An existing account is never promoted.
+	a.Config.AdminEmail = "customer@example.com"
+	if err := a.BootstrapAdmin(ctx); err == nil {
+		t.Error("BootstrapAdmin accepted a customer's email")
+	}
+}
+
+func TestPaymentFailureReasons(t *testing.T) {
+	a := newTestApp(t, nil)
+	merchant := signUp(t, a, "merchant")
+	customer := signUp(t, a, "customer")
+
+	rec := serve(a, http.MethodPost, "/api/v1/product/merchant/", merchant, `{"name":"Red Shirt","price":60000,"quantity":5}`)
+	var created struct {
+		Product struct {
+			Id int64 `json:"id"`
+		} `json:"product"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); rec.Code != http.StatusCreated || err != nil {
+		t.Fatalf("create product = %d: %s", rec.Code, rec.Body)
+	}
+	checkout := func() string {
+		t.Helper()
+		rec := serve(a, http.MethodPost, "/api/v1/transactions/customer/", customer,
+			fmt.Sprintf(`{"product_id":%d,"quantity":1}`, created.Product.Id))
+		var body struct {
+			Transaction struct {
+				Id int64 `json:"id"`
+			} `json:"transaction"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); rec.Code != http.StatusCreated || err != nil {
+			t.Fatalf("checkout = %d: %s", rec.Code, rec.Body)
+		}
+		return strconv.FormatInt(body.Transaction.Id, 10)
+	}
+	pay := func(id string, want int) {
+		t.Helper()
+		if rec := serve(a, http.MethodPost, "/api/v1/transactions/customer/"+id+"/pay", customer, ""); rec.Code != want {
+			t.Errorf("pay %s = %d, want %d: %s", id, rec.Code, want, rec.Body)
+		}
+	}
+
+	paid, expired := checkout(), checkout()
+	pay(paid, http.StatusOK)
+	pay(paid, http.StatusConflict)
+	pay("999", http.StatusNotFound)
+	a.DB.Model(&models.StockReservation{}).Where("transaction_id = ?", expired).Update("expires_at", time.Now().Add(-time.Minute))
+	pay(expired, http.StatusConflict)
+
+	body := serve(a, http.MethodGet, "/metrics", "", "").Body.String()
+	for _, reason := range []string{metrics.ReasonTransactionStatus, metrics.ReasonTransactionNotFound, metrics.ReasonReservationExpired} {
+		if want := `shop_checkout_failures_total{reason="` + reason + `"} 1`; !strings.Contains(body, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if strings.Contains(body, `reason="`+metrics.ReasonInternalError+`"`) {
+		t.Errorf("client errors were counted as internal errors")
+	}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	a := newTestApp(t, exporter)
+	exporter.Reset()
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	rec := serve(a, http.MethodPost, "/api/v1/users/login", "", `{"email":"nobody@example.com","password":"Passw0rd"}`,
+		"traceparent", traceparent)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("login = %d: %s", rec.Code, rec.Body)
+	}
+
+	spans := exporter.GetSpans()
+	var server *tracetest.SpanStub
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindServer {
+			server = &spans[i]
+		}
+	}
+	if server == nil {
+		t.Fatalf("no server span among %d spans", len(spans))
+	}
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span trace ID = %s, want the incoming traceparent's", got)
+	}
+	if got := server.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the incoming traceparent's", got)
+	}
+
+	var queries int
+	for _, span := range spans {
+		if span.Name != "gorm.query" {
+			continue
+		}
+		queries++
+		if span.SpanKind != trace.SpanKindClient {
+			t.Errorf("gorm.query kind = %v, want client", span.SpanKind)
+		}
+		if span.Parent.SpanID() != server.SpanContext.SpanID() {
+			t.Errorf("gorm.query parent = %s, want the server span %s", span.Parent.SpanID(), server.SpanContext.SpanID())
+		}
+		attrs := attribute.NewSet(span.Attributes...)
+		if system, _ := attrs.Value("db.system"); system.AsString() != "sqlite" {
+			t.Errorf("gorm.query db.system = %q, want sqlite", system.AsString())
+		}
+		if table, _ := attrs.Value("db.sql.table"); table.AsString() != "users" {
+			t.Errorf("gorm.query db.sql.table = %q, want users", table.AsString())
+		}
+	}
+	if queries == 0 {
+		t.Errorf("no gorm.query span among %v", spanNames(spans))
+	}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
@@ -0,0 +1,225 @@
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Code is a stable, machine-readable error identifier. Clients switch on it,
+// so existing values must never change meaning.
+type Code string
+
+const (
+	CodeValidationFailed     Code = "VALIDATION_FAILED"
+	CodeUnauthorized         Code = "UNAUTHORIZED"
+	CodeInvalidToken         Code = "INVALID_TOKEN"
+	CodeInvalidCredentials   Code = "INVALID_CREDENTIALS"
+	CodeForbidden            Code = "FORBIDDEN"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeMethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	CodeUserNotFound         Code = "USER_NOT_FOUND"
+	CodeEmailTaken           Code = "EMAIL_ALREADY_REGISTERED"
+	CodeProductNotFound      Code = "PRODUCT_NOT_FOUND"
+	CodeTransactionNotFound  Code = "TRANSACTION_NOT_FOUND"
+	CodeInsufficientStock    Code = "INSUFFICIENT_STOCK"
+	CodeReservationExpired   Code = "RESERVATION_EXPIRED"
+	CodeTransactionStatus    Code = "INVALID_TRANSACTION_STATUS"
+	CodeVariantNotFound      Code = "VARIANT_NOT_FOUND"
+	CodeVariantReserved      Code = "VARIANT_RESERVED"
+	CodeSkuTaken             Code = "SKU_ALREADY_EXISTS"
+	CodeImageNotFound        Code = "IMAGE_NOT_FOUND"
+	CodeImportNotFound       Code = "IMPORT_NOT_FOUND"
+	CodeNotificationNotFound Code = "NOTIFICATION_NOT_FOUND"
+	CodeSubscriptionNotFound Code = "SUBSCRIPTION_NOT_FOUND"
+	CodeInStock              Code = "PRODUCT_IN_STOCK"
+	CodeReviewNotFound       Code = "REVIEW_NOT_FOUND"
+	CodeReviewExists         Code = "REVIEW_ALREADY_EXISTS"
+	CodeNotVerifiedBuyer     Code = "NOT_VERIFIED_BUYER"
+	CodeWishlistNotFound     Code = "WISHLIST_NOT_FOUND"
+	CodeWishlistNameTaken    Code = "WISHLIST_NAME_TAKEN"
+	CodeWishlistItemNotFound Code = "WISHLIST_ITEM_NOT_FOUND"
+	CodePayloadTooLarge      Code = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	CodeCategoryNotFound     Code = "CATEGORY_NOT_FOUND"
+	CodeCategorySlugTaken    Code = "CATEGORY_SLUG_TAKEN"
+	CodeCategoryHasChildren  Code = "CATEGORY_HAS_CHILDREN"
+	CodePreconditionNeeded   Code = "PRECONDITION_REQUIRED"
+	CodePreconditionFailed   Code = "PRECONDITION_FAILED"
+	CodeTimeout              Code = "TIMEOUT"
+	CodeServiceUnavailable   Code = "SERVICE_UNAVAILABLE"
+	CodeInternal             Code = "INTERNAL_ERROR"
+)
+
+// FieldError describes why a single input field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error is the single error type handlers report. The middleware in
+// routes/middleware renders it; Err is kept for logs and never sent.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New builds an Error with the given status, code and client-facing message.
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap attaches the underlying cause to e.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeValidationFailed, message)
+}
+
+func Unauthorized(code Code, message string) *Error {
+	return New(http.StatusUnauthorized, code, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(code Code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+func Conflict(code Code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+// Internal reports a failure the client can't fix. A cause that is the
+// request deadline expiring becomes 504 TIMEOUT instead of 500.
+func Internal(err error, message string) *Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return New(http.StatusGatewayTimeout, CodeTimeout, "The request took too long to complete").Wrap(err)
+	}
+	return New(http.StatusInternalServerError, CodeInternal, message).Wrap(err)
+}
+
+// Validation converts an error returned by gin's binding into a
+// VALIDATION_FAILED error with one detail per offending field.
+func Validation(err error) *Error {
+	appErr := BadRequest("The request is invalid").Wrap(err)
+
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrors):
+		for _, fieldError := range validationErrors {
+			appErr.Details = append(appErr.Details, FieldError{
+				Field:   fieldName(fieldError),
+				Rule:    fieldError.Tag(),
+				Message: fieldMessage(fieldError),
+			})
+		}
+	case errors.As(err, &typeError):
+		appErr.Details = append(appErr.Details, FieldError{
+			Field:   typeError.Field,
+			Rule:    "type",
+			Message: "must be a " + typeError.Type.String(),
+		})
+	case errors.As(err, &syntaxError), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		appErr.Message = "The request body is not valid JSON"
+	default:
+		appErr.Message = err.Error()
+	}
+	return appErr
+}
+
+// fieldName drops the struct name from the namespace, so nested fields read
+// "items[0].quantity" rather than "Request.items[0].quantity".
+func fieldName(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fieldError.Field()
+}
+
+func fieldMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fieldError.Param()
+	case "max", "lte":
+		return "must be at most " + fieldError.Param()
+	case "gt":
+		return "must be greater than " + fieldError.Param()
+	case "lt":
+		return "must be less than " + fieldError.Param()
+	case "unique":
+		return "must not contain duplicates"
+	case "oneof":
+		return "must be one of: " + fieldError.Param()
+	case "email":
+		return "must be a valid email address"
+	case "notblank":
+		return "must not be blank"
+	case "password":
+		return "must be 8 to 72 characters long and contain an upper case letter, a lower case letter and a digit"
+	case "slug":
+		return "must contain only lower case letters, digits and single hyphens"
+	case "money":
+		return "must be an amount between 0 and 1000000000 with at most two decimal places"
+	case "gtefield":
+		return "must be greater than or equal to " + snakeCase(fieldError.Param())
+	default:
+		return "failed the " + fieldError.Tag() + " rule"
+	}
+}
+
+// Abort attaches err to the request for the error-handling middleware to
+// render and stops the remaining handlers.
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// snakeCase turns a Go field name such as "MinPrice" into "min_price", the
+// spelling clients use for cross-field rule parameters.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package apperror
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ProblemContentType is the RFC 7807 media type.
+const ProblemContentType = "application/problem+json"
+
+// Envelope is the default JSON error body:
+//
+//	{"error": {"code": "PRODUCT_NOT_FOUND", "message": "Product not found"}}
+type Envelope struct {
+	Error Body `json:"error"`
+}
+
+type Body struct {
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Problem is the RFC 7807 rendering of an Error. Code and Errors are
+// extension members carrying the same data as the envelope.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+func (e *Error) Envelope(requestID string) Envelope {
+	return Envelope{Error: Body{
+		Code:      e.Code,
+		Message:   e.Message,
+		Details:   e.Details,
+		RequestID: requestID,
+	}}
+}
+
+// Problem renders e for the given request path. The type URI is a relative
+// reference derived from the code, e.g. "/errors/product-not-found".
+func (e *Error) Problem(instance, requestID string) Problem {
+	return Problem{
+		Type:      "/errors/" + strings.ReplaceAll(strings.ToLower(string(e.Code)), "_", "-"),
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Message,
+		Instance:  instance,
+		Code:      e.Code,
+		Errors:    e.Details,
+		RequestID: requestID,
+	}
+}
@@ -0,0 +1,60 @@
+// Package bulk reads and writes product rows as CSV or NDJSON (JSON Lines)
+// for bulk imports and exports.
+package bulk
+
+import (
+	"fmt"
+	"mime"
+)
+
+// Supported file formats.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Columns are the CSV columns, in the order exports write them.
+var Columns = []string{"sku", "name", "description", "price", "quantity", "categories"}
+
+// requiredColumns must appear in the header of an imported CSV file.
+var requiredColumns = []string{"sku", "name", "price", "quantity"}
+
+// CategorySeparator separates the category slugs in a CSV cell.
+const CategorySeparator = "|"
+
+// RowError means a single row couldn't be parsed. Reading can carry on with
+// the next row.
+type RowError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+func (e *RowError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s %s", e.Field, e.Message)
+}
+
+// ContentType is the media type of files in format.
+func ContentType(format string) string {
+	if format == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FormatOf guesses the format of a request body from its Content-Type, and
+// returns "" for anything else.
+func FormatOf(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return FormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return FormatNDJSON
+	default:
+		return ""
+	}
+}
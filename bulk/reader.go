@@ -0,0 +1,180 @@
+package bulk
+
+import (
+	"backend-hanssen-hilman/models"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxLineBytes bounds one NDJSON line.
+const maxLineBytes = 1 << 20
+
+// Reader reads product rows one at a time.
+type Reader interface {
+	// Read returns the next row and its number, counted from 1 without the
+	// CSV header, or io.EOF after the last one. A *RowError only concerns
+	// that row; any other error ends the file.
+	Read() (models.ProductImportRow, int64, error)
+}
+
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.ReuseRecord = true
+		return &csvReader{reader: reader}, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxLineBytes)
+		return &ndjsonReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	row     int64
+}
+
+func (r *csvReader) Read() (models.ProductImportRow, int64, error) {
+	var row models.ProductImportRow
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return row, 0, err
+		}
+	}
+
+	record, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return row, 0, io.EOF
+	}
+	r.row++
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return row, r.row, &RowError{Rule: "csv", Message: parseErr.Err.Error()}
+	}
+	if err != nil {
+		return row, r.row, err
+	}
+
+	cell := func(column string) (string, bool) {
+		i, ok := r.columns[column]
+		if !ok {
+			return "", false
+		}
+		return strings.TrimSpace(record[i]), true
+	}
+
+	row.Sku, _ = cell("sku")
+	row.Name, _ = cell("name")
+	row.Description, _ = cell("description")
+	if value, _ := cell("price"); value != "" {
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return row, r.row, &RowError{Field: "price", Rule: "number", Message: "must be a number"}
+		}
+		row.Price = &price
+	}
+	if value, _ := cell("quantity"); value != "" {
+		quantity, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return row, r.row, &RowError{Field: "quantity", Rule: "integer", Message: "must be a whole number"}
+		}
+		row.Quantity = &quantity
+	}
+	if value, ok := cell("categories"); ok {
+		categories := []string{}
+		for _, slug := range strings.Split(value, CategorySeparator) {
+			if slug = strings.TrimSpace(slug); slug != "" {
+				categories = append(categories, slug)
+			}
+		}
+		row.Categories = &categories
+	}
+	return row, r.row, nil
+}
+
+func (r *csvReader) readHeader() error {
+	header, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return errors.New("the file is empty")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(Columns, name) {
+			return fmt.Errorf("unknown column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("missing column %q", name)
+		}
+	}
+	r.columns = columns
+	// Every record must now have one cell per column.
+	r.reader.FieldsPerRecord = len(header)
+	return nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	row     int64
+}
+
+func (r *ndjsonReader) Read() (models.ProductImportRow, int64, error) {
+	var row models.ProductImportRow
+	for r.scanner.Scan() {
+		r.row++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row); err != nil {
+			return row, r.row, jsonRowError(err)
+		}
+		if decoder.More() {
+			return row, r.row, &RowError{Rule: "json", Message: "must hold a single JSON object"}
+		}
+		return row, r.row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return row, r.row + 1, fmt.Errorf("line %d is longer than %d bytes", r.row+1, maxLineBytes)
+		}
+		return row, r.row, err
+	}
+	return row, 0, io.EOF
+}
+
+func jsonRowError(err error) *RowError {
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return &RowError{Field: typeError.Field, Rule: "type", Message: "must be a " + typeError.Type.String()}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &RowError{Field: strings.Trim(field, `"`), Rule: "unknown", Message: "is not a product field"}
+	}
+	return &RowError{Rule: "json", Message: "is not a valid JSON object"}
+}
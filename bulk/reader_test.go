@@ -0,0 +1,136 @@
+package bulk
+
+import (
+	"backend-hanssen-hilman/models"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// read is what one Read call returned: the SKU of a parsed row, or the row
+// error.
+type read struct {
+	Row int64
+	Sku string
+	Err *RowError
+}
+
+// readAll reads input to the end and returns every row, and the error that
+// ended the file, if any.
+func readAll(t *testing.T, format, input string) ([]read, error) {
+	t.Helper()
+	reader, err := NewReader(format, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var reads []read
+	for {
+		row, n, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return reads, nil
+		}
+		var rowErr *RowError
+		switch {
+		case errors.As(err, &rowErr):
+			reads = append(reads, read{Row: n, Err: rowErr})
+		case err != nil:
+			return reads, err
+		default:
+			reads = append(reads, read{Row: n, Sku: row.Sku})
+		}
+	}
+}
+
+func TestReaderRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    []read
+		wantErr string
+	}{
+		{"csv", FormatCSV, "sku,name,price,quantity\nA,Shirt,10,1\nB,Socks,2.5,0\n",
+			[]read{{Row: 1, Sku: "A"}, {Row: 2, Sku: "B"}}, ""},
+		{"csv reordered columns with BOM", FormatCSV, "\ufeffQuantity, Price ,name,sku\n1,10,Shirt,A\n",
+			[]read{{Row: 1, Sku: "A"}}, ""},
+		{"csv blank lines", FormatCSV, "sku,name,price,quantity\n\nA,Shirt,10,1\n\n\nB,Socks,3,1",
+			[]read{{Row: 1, Sku: "A"}, {Row: 2, Sku: "B"}}, ""},
+		{"csv bad price", FormatCSV, "sku,name,price,quantity\nA,Shirt,ten,1\nB,Socks,3,1\n",
+			[]read{{Row: 1, Err: &RowError{Field: "price", Rule: "number", Message: "must be a number"}}, {Row: 2, Sku: "B"}}, ""},
+		{"csv bad quantity", FormatCSV, "sku,name,price,quantity\nA,Shirt,10,1.5\n",
+			[]read{{Row: 1, Err: &RowError{Field: "quantity", Rule: "integer", Message: "must be a whole number"}}}, ""},
+		{"csv short row", FormatCSV, "sku,name,price,quantity\nA,Shirt,10\nB,Socks,3,1\n",
+			[]read{{Row: 1, Err: &RowError{Rule: "csv", Message: "wrong number of fields"}}, {Row: 2, Sku: "B"}}, ""},
+		{"csv bad quoting", FormatCSV, "sku,name,price,quantity\nA,\"Shirt,10,1\n",
+			[]read{{Row: 1, Err: &RowError{Rule: "csv", Message: "extraneous or missing \" in quoted-field"}}}, ""},
+		{"csv empty file", FormatCSV, "", nil, "the file is empty"},
+		{"csv unknown column", FormatCSV, "sku,name,price,quantity,colour\n", nil, `unknown column "colour"`},
+		{"csv duplicate column", FormatCSV, "sku,name,price,quantity,SKU\n", nil, `duplicate column "sku"`},
+		{"csv missing column", FormatCSV, "sku,name,price\nA,Shirt,10\n", nil, `missing column "quantity"`},
+
+		{"ndjson", FormatNDJSON, `{"sku":"A","name":"Shirt","price":10,"quantity":1}` + "\n" + `{"sku":"B"}`,
+			[]read{{Row: 1, Sku: "A"}, {Row: 2, Sku: "B"}}, ""},
+		{"ndjson blank lines", FormatNDJSON, "\n" + `{"sku":"A"}` + "\n  \n" + `{"sku":"B"}` + "\n\n",
+			[]read{{Row: 2, Sku: "A"}, {Row: 4, Sku: "B"}}, ""},
+		{"ndjson wrong type", FormatNDJSON, `{"sku":"A","price":"10"}` + "\n" + `{"sku":"B"}`,
+			[]read{{Row: 1, Err: &RowError{Field: "price", Rule: "type", Message: "must be a float64"}}, {Row: 2, Sku: "B"}}, ""},
+		{"ndjson unknown field", FormatNDJSON, `{"sku":"A","colour":"red"}`,
+			[]read{{Row: 1, Err: &RowError{Field: "colour", Rule: "unknown", Message: "is not a product field"}}}, ""},
+		{"ndjson malformed", FormatNDJSON, `{"sku":"A"` + "\n" + `{"sku":"B"}`,
+			[]read{{Row: 1, Err: &RowError{Rule: "json", Message: "is not a valid JSON object"}}, {Row: 2, Sku: "B"}}, ""},
+		{"ndjson two objects", FormatNDJSON, `{"sku":"A"} {"sku":"B"}`,
+			[]read{{Row: 1, Err: &RowError{Rule: "json", Message: "must hold a single JSON object"}}}, ""},
+		{"ndjson line too long", FormatNDJSON, `{"sku":"A"}` + "\n" + `{"name":"` + strings.Repeat("x", maxLineBytes) + `"}`,
+			[]read{{Row: 1, Sku: "A"}}, "line 2 is longer than 1048576 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAll(t, tt.format, tt.input)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %+v, want %+v", got, tt.want)
+			}
+			var errText string
+			if err != nil {
+				errText = err.Error()
+			}
+			if errText != tt.wantErr {
+				t.Errorf("error = %q, want %q", errText, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReaderFields(t *testing.T) {
+	price, quantity := 19.99, int64(3)
+	want := models.ProductImportRow{
+		Sku: "A", Name: "Shirt", Description: "Soft, red", Price: &price, Quantity: &quantity,
+		Categories: &[]string{"tops", "sale"},
+	}
+	inputs := map[string]string{
+		FormatCSV:    "sku,name,description,price,quantity,categories\n A , Shirt ,\"Soft, red\",19.99,3,tops| |sale\n",
+		FormatNDJSON: `{"sku":"A","name":"Shirt","description":"Soft, red","price":19.99,"quantity":3,"categories":["tops","sale"]}`,
+	}
+	for format, input := range inputs {
+		reader, err := NewReader(format, strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("NewReader(%s): %v", format, err)
+		}
+		got, _, err := reader.Read()
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s row = %+v (%v), want %+v", format, got, err, want)
+		}
+	}
+
+	// An empty categories cell clears the categories; a missing column keeps them.
+	for input, wantCategories := range map[string]*[]string{
+		"sku,name,price,quantity,categories\nA,Shirt,1,1,\n": {},
+		"sku,name,price,quantity\nA,Shirt,1,1\n":             nil,
+	} {
+		reader, _ := NewReader(FormatCSV, strings.NewReader(input))
+		if got, _, err := reader.Read(); err != nil || !reflect.DeepEqual(got.Categories, wantCategories) {
+			t.Errorf("categories of %q = %v (%v), want %v", input, got.Categories, err, wantCategories)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package bulk
+
+import (
+	"backend-hanssen-hilman/models"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writer writes product rows in one format. Rows are buffered until Flush.
+type Writer interface {
+	Write(row models.ProductImportRow) error
+	Flush() error
+}
+
+// NewWriter starts a file in format on w. CSV files begin with the header.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(Columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{writer: writer}, nil
+	case FormatNDJSON:
+		buffered := bufio.NewWriter(w)
+		encoder := json.NewEncoder(buffered)
+		encoder.SetEscapeHTML(false)
+		return &ndjsonWriter{buffered: buffered, encoder: encoder}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func (w *csvWriter) Write(row models.ProductImportRow) error {
+	var price, quantity, categories string
+	if row.Price != nil {
+		price = strconv.FormatFloat(*row.Price, 'f', -1, 64)
+	}
+	if row.Quantity != nil {
+		quantity = strconv.FormatInt(*row.Quantity, 10)
+	}
+	if row.Categories != nil {
+		categories = strings.Join(*row.Categories, CategorySeparator)
+	}
+	w.record = append(w.record[:0], row.Sku, row.Name, row.Description, price, quantity, categories)
+	return w.writer.Write(w.record)
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(row models.ProductImportRow) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return w.buffered.Flush()
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/logging"
+	"backend-hanssen-hilman/notify"
+	"backend-hanssen-hilman/storage"
+	"backend-hanssen-hilman/tracing"
+	"backend-hanssen-hilman/util"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config holds every setting the application needs to start.
+type Config struct {
+	Port      string
+	JWTSecret string
+	Database  *database.DBConfig
+	Log       *logging.Config
+	Tracing   *tracing.Config
+	Storage   *storage.Config
+	Notify    *notify.Config
+
+	// ReadinessTimeout bounds the database ping behind /readyz.
+	ReadinessTimeout time.Duration
+
+	// ProblemJSON renders every error as RFC 7807 application/problem+json
+	// instead of only when the client asks for it.
+	ProblemJSON bool
+
+	// QueryTimeout is the per-request deadline for database work, and
+	// QueryTimeouts overrides it for individual "METHOD /route" endpoints.
+	QueryTimeout  time.Duration
+	QueryTimeouts map[string]time.Duration
+
+	// ProductRetention is how long soft-deleted products are kept before the
+	// purge job, which runs every ProductPurgeInterval, removes them.
+	ProductRetention     time.Duration
+	ProductPurgeInterval time.Duration
+
+	// SearchReindexInterval is how often the in-memory search index is
+	// rebuilt from the database, picking up writes made by other instances.
+	SearchReindexInterval time.Duration
+
+	// ReservationTTL is how long a checkout holds its stock while waiting for
+	// payment. Expired reservations are released every
+	// ReservationSweepInterval.
+	ReservationTTL           time.Duration
+	ReservationSweepInterval time.Duration
+
+	// InventoryReconcileInterval is how often stock counts are checked
+	// against the inventory ledger.
+	InventoryReconcileInterval time.Duration
+
+	// StockAlertInterval is how often new stock movements are checked for
+	// low-stock and back-in-stock notifications.
+	StockAlertInterval time.Duration
+
+	// ImportMaxBytes bounds the size of a bulk product import file.
+	ImportMaxBytes int64
+
+	// CursorSecret signs pagination cursors. It defaults to JWTSecret.
+	CursorSecret string
+
+	// AdminEmail and AdminPassword, when both are set, name an admin account
+	// that is created at startup if no user has that email yet. Admins can't
+	// register through the API.
+	AdminEmail    string
+	AdminPassword string
+}
+
+// Load builds the application configuration from environment variables.
+func Load() *Config {
+	cfg := &Config{
+		Port:      os.Getenv("PORT"),
+		JWTSecret: os.Getenv("JWT_SECRET"),
+		Database:  database.BuildConfig(),
+		Log:       logging.BuildConfig(),
+		Tracing:   tracing.BuildConfig(),
+		Storage:   storage.BuildConfig(),
+		Notify:    notify.BuildConfig(),
+
+		ReadinessTimeout: util.GetEnvDuration("READINESS_TIMEOUT", 2*time.Second),
+
+		ProblemJSON: os.Getenv("ERROR_FORMAT") == "problem",
+
+		QueryTimeout:  util.GetEnvDuration("QUERY_TIMEOUT", 5*time.Second),
+		QueryTimeouts: parseTimeouts(os.Getenv("QUERY_TIMEOUTS")),
+
+		ProductRetention:     util.GetEnvDuration("PRODUCT_RETENTION", 30*24*time.Hour),
+		ProductPurgeInterval: util.GetEnvDuration("PRODUCT_PURGE_INTERVAL", time.Hour),
+
+		SearchReindexInterval: util.GetEnvDuration("SEARCH_REINDEX_INTERVAL", 10*time.Minute),
+
+		ReservationTTL:             util.GetEnvDuration("RESERVATION_TTL", 15*time.Minute),
+		ReservationSweepInterval:   util.GetEnvDuration("RESERVATION_SWEEP_INTERVAL", time.Minute),
+		InventoryReconcileInterval: util.GetEnvDuration("INVENTORY_RECONCILE_INTERVAL", time.Hour),
+		StockAlertInterval:         util.GetEnvDuration("STOCK_ALERT_INTERVAL", 30*time.Second),
+
+		ImportMaxBytes: int64(util.GetEnvInt("IMPORT_MAX_BYTES", 100<<20)),
+
+		CursorSecret: os.Getenv("CURSOR_SECRET"),
+
+		AdminEmail:    os.Getenv("ADMIN_EMAIL"),
+		AdminPassword: os.Getenv("ADMIN_PASSWORD"),
+	}
+	if cfg.CursorSecret == "" {
+		cfg.CursorSecret = cfg.JWTSecret
+	}
+	return cfg
+}
+
+// parseTimeouts reads a comma separated list of "METHOD /route=duration"
+// entries, e.g. "GET /api/v1/products/=2s,POST /api/v1/transactions/customer/=10s".
+func parseTimeouts(value string) map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, rawTimeout, found := strings.Cut(entry, "=")
+		timeout, err := time.ParseDuration(strings.TrimSpace(rawTimeout))
+		if !found || err != nil {
+			slog.Warn("Ignoring invalid QUERY_TIMEOUTS entry", "entry", entry)
+			continue
+		}
+		timeouts[strings.Join(strings.Fields(route), " ")] = timeout
+	}
+	return timeouts
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/bulk"
+	"backend-hanssen-hilman/jobs"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is how many products an export loads at a time.
+const exportBatchSize = 500
+
+// BulkController imports and exports a merchant's catalog as CSV or NDJSON.
+type BulkController struct {
+	productRepo repositories.ProductRepository
+	importRepo  repositories.ImportJobRepository
+	importer    *jobs.ProductImporter
+	maxBytes    int64
+}
+
+func NewBulkController(productRepo repositories.ProductRepository, importRepo repositories.ImportJobRepository, importer *jobs.ProductImporter, maxBytes int64) *BulkController {
+	return &BulkController{productRepo: productRepo, importRepo: importRepo, importer: importer, maxBytes: maxBytes}
+}
+
+// ImportProducts takes the file as the raw request body and imports it in
+// the background. The response is the pending job, whose progress can be
+// followed at the Location it returns.
+func (c *BulkController) ImportProducts(ctx *gin.Context) {
+	var req models.ProductImportQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+	format := req.Format
+	if format == "" {
+		format = bulk.FormatOf(ctx.ContentType())
+	}
+	if format == "" {
+		apperror.Abort(ctx, apperror.New(http.StatusUnsupportedMediaType, apperror.CodeUnsupportedMediaType, "Imports must be CSV or NDJSON"))
+		return
+	}
+
+	// The file is spooled to disk so the job can outlive the request
+	// without holding a large file in memory.
+	file, err := os.CreateTemp("", "product-import-*."+format)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to store import file"))
+		return
+	}
+	_, err = io.Copy(file, http.MaxBytesReader(ctx.Writer, ctx.Request.Body, c.maxBytes))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			apperror.Abort(ctx, apperror.New(http.StatusRequestEntityTooLarge, apperror.CodePayloadTooLarge,
+				fmt.Sprintf("Import files must be at most %d bytes", c.maxBytes)))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to store import file"))
+		}
+		return
+	}
+
+	job := models.ImportJob{
+		MerchantId: ctx.GetInt64("user_id"),
+		Format:     format,
+		DryRun:     req.DryRun,
+		Status:     models.ImportStatusPending,
+	}
+	if err := c.importRepo.CreateImportJob(ctx.Request.Context(), &job); err != nil {
+		os.Remove(file.Name())
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to start import"))
+		return
+	}
+
+	// The job gets its own copy, as it keeps updating it after the response.
+	running := job
+	go c.importer.Run(context.WithoutCancel(ctx.Request.Context()), &running, file.Name())
+
+	ctx.Header("Location", strings.TrimSuffix(ctx.Request.URL.Path, "/import")+"/imports/"+strconv.FormatInt(job.Id, 10))
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "Import started", "import": job})
+}
+
+func (c *BulkController) GetImportJob(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("jobId"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid import ID"))
+		return
+	}
+
+	// Progress is written to the primary, so reading it from a replica
+	// would lag behind.
+	job, err := c.importRepo.UsePrimary().GetImportJob(ctx.Request.Context(), ctx.GetInt64("user_id"), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeImportNotFound, "Import not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve import"))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, job)
+}
+
+// ExportProducts streams the merchant's products in the import format, a
+// batch at a time. Products without a SKU are exported with an empty one
+// and need one before the file can be imported again.
+func (c *BulkController) ExportProducts(ctx *gin.Context) {
+	var req models.ProductExportQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+	merchantId := ctx.GetInt64("user_id")
+
+	// A large catalog takes longer than the request deadline to stream, so
+	// the batches are read without it. A client that goes away makes the
+	// next write fail instead.
+	dbCtx := context.WithoutCancel(ctx.Request.Context())
+
+	// The first batch is read before anything is written, so a failure can
+	// still be reported as an error response.
+	products, err := c.productRepo.ListMerchantProducts(dbCtx, merchantId, 0, exportBatchSize)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to export products"))
+		return
+	}
+
+	ctx.Header("Content-Type", bulk.ContentType(req.Format))
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="products-%s.%s"`, time.Now().Format("20060102"), req.Format))
+	ctx.Status(http.StatusOK)
+
+	writer, err := bulk.NewWriter(req.Format, ctx.Writer)
+	if err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "Failed to export products", "error", err)
+		return
+	}
+	for {
+		for _, product := range products {
+			if err := writer.Write(exportRow(&product)); err != nil {
+				slog.WarnContext(ctx.Request.Context(), "Product export interrupted", "error", err)
+				return
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			slog.WarnContext(ctx.Request.Context(), "Product export interrupted", "error", err)
+			return
+		}
+		ctx.Writer.Flush()
+
+		if len(products) < exportBatchSize {
+			return
+		}
+		products, err = c.productRepo.ListMerchantProducts(dbCtx, merchantId, products[len(products)-1].Id, exportBatchSize)
+		if err != nil {
+			// The status line is already sent; the client sees a truncated file.
+			slog.ErrorContext(ctx.Request.Context(), "Failed to export products", "error", err)
+			return
+		}
+	}
+}
+
+func exportRow(product *models.Product) models.ProductImportRow {
+	row := models.ProductImportRow{
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       &product.Price,
+		Quantity:    &product.Quantity,
+	}
+	if product.Sku != nil {
+		row.Sku = *product.Sku
+	}
+	categories := make([]string, 0, len(product.Categories))
+	for _, category := range product.Categories {
+		categories = append(categories, category.Slug)
+	}
+	row.Categories = &categories
+	return row
+}
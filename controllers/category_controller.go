@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CategoryController struct {
+	categoryRepo repositories.CategoryRepository
+}
+
+func NewCategoryController(categoryRepo repositories.CategoryRepository) *CategoryController {
+	return &CategoryController{categoryRepo: categoryRepo}
+}
+
+// ListCategories returns the whole category tree with product counts.
+func (c *CategoryController) ListCategories(ctx *gin.Context) {
+	tree, err := c.categoryRepo.CategoryTree(ctx.Request.Context())
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list categories"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"categories": tree})
+}
+
+func (c *CategoryController) CreateCategory(ctx *gin.Context) {
+	var req models.CategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	category := models.Category{}
+	if !c.applyRequest(ctx, &category, req) {
+		return
+	}
+
+	if err := c.categoryRepo.CreateCategory(ctx.Request.Context(), &category); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to create category"))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Category created successfully", "category": category})
+}
+
+func (c *CategoryController) UpdateCategory(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid category ID"))
+		return
+	}
+	var req models.CategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	category, err := c.categoryRepo.UsePrimary().GetCategoryByID(ctx.Request.Context(), id)
+	if err != nil {
+		abortCategoryLookup(ctx, err)
+		return
+	}
+	if !c.applyRequest(ctx, category, req) {
+		return
+	}
+
+	if err := c.categoryRepo.UpdateCategory(ctx.Request.Context(), category); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to update category"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Category updated successfully", "category": category})
+}
+
+// DeleteCategory removes a category without subcategories. Its products stay
+// and simply lose the category.
+func (c *CategoryController) DeleteCategory(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid category ID"))
+		return
+	}
+
+	hasChildren, err := c.categoryRepo.UsePrimary().HasChildren(ctx.Request.Context(), id)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to delete category"))
+		return
+	}
+	if hasChildren {
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeCategoryHasChildren, "Move or delete the subcategories first"))
+		return
+	}
+
+	if err := c.categoryRepo.DeleteCategory(ctx.Request.Context(), id); err != nil {
+		abortCategoryLookup(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// applyRequest copies req onto category after checking that the slug is free
+// and that the new parent exists and doesn't sit below the category itself.
+func (c *CategoryController) applyRequest(ctx *gin.Context, category *models.Category, req models.CategoryRequest) bool {
+	repo := c.categoryRepo.UsePrimary()
+
+	existing, err := repo.GetCategoryBySlug(ctx.Request.Context(), req.Slug)
+	if err == nil && existing.Id != category.Id {
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeCategorySlugTaken, "Category with this slug already exists"))
+		return false
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve category"))
+		return false
+	}
+
+	if req.ParentId != nil {
+		categories, err := repo.ListCategories(ctx.Request.Context())
+		if err != nil {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve category"))
+			return false
+		}
+
+		found := false
+		for _, candidate := range categories {
+			found = found || candidate.Id == *req.ParentId
+		}
+		if category.Id != 0 {
+			for _, descendant := range repositories.DescendantIDs(categories, category.Id) {
+				if descendant == *req.ParentId {
+					found = false
+				}
+			}
+		}
+		if !found {
+			appErr := apperror.BadRequest("The request is invalid")
+			appErr.Details = []apperror.FieldError{{Field: "parent_id", Rule: "parent", Message: "must be an existing category outside this category's subtree"}}
+			apperror.Abort(ctx, appErr)
+			return false
+		}
+	}
+
+	category.Name = req.Name
+	category.Slug = req.Slug
+	category.ParentId = req.ParentId
+	category.Position = req.Position
+	return true
+}
+
+func abortCategoryLookup(ctx *gin.Context, err error) {
+	if err == gorm.ErrRecordNotFound {
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeCategoryNotFound, "Category not found"))
+	} else {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve category"))
+	}
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/database"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type HealthController struct {
+	db           *gorm.DB
+	readyTimeout time.Duration
+}
+
+func NewHealthController(db *gorm.DB, readyTimeout time.Duration) *HealthController {
+	return &HealthController{
+		db:           db,
+		readyTimeout: readyTimeout,
+	}
+}
+
+// Liveness reports that the process is up and serving HTTP.
+func (c *HealthController) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness reports whether the database answers a ping in time, so that
+// orchestrators stop routing traffic to an instance that cannot serve it.
+func (c *HealthController) Readiness(ctx *gin.Context) {
+	if err := database.Ping(ctx.Request.Context(), c.db, c.readyTimeout); err != nil {
+		apperror.Abort(ctx, apperror.New(http.StatusServiceUnavailable, apperror.CodeServiceUnavailable, "Database is not reachable").Wrap(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
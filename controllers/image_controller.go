@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/imaging"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/storage"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ImageController struct {
+	productRepo   repositories.ProductRepository
+	imageRepo     repositories.ImageRepository
+	store         storage.BlobStore
+	maxBytes      int64
+	thumbnailSize int
+}
+
+func NewImageController(productRepo repositories.ProductRepository, imageRepo repositories.ImageRepository, store storage.BlobStore, maxBytes int64, thumbnailSize int) *ImageController {
+	return &ImageController{
+		productRepo:   productRepo,
+		imageRepo:     imageRepo,
+		store:         store,
+		maxBytes:      maxBytes,
+		thumbnailSize: thumbnailSize,
+	}
+}
+
+// UploadImage accepts a multipart form with the file in the "image" field.
+// The type is sniffed from the content rather than trusted from the client.
+func (c *ImageController) UploadImage(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+
+	// Leave room for the multipart headers around the file itself.
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, c.maxBytes+64<<10)
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return
+	}
+
+	file, header, err := ctx.Request.FormFile("image")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.abortTooLarge(ctx)
+			return
+		}
+		appErr := apperror.BadRequest("The request is invalid").Wrap(err)
+		appErr.Details = []apperror.FieldError{{Field: "image", Rule: "required", Message: "must be a file in a multipart/form-data body"}}
+		apperror.Abort(ctx, appErr)
+		return
+	}
+	defer file.Close()
+	if header.Size > c.maxBytes {
+		c.abortTooLarge(ctx)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, c.maxBytes+1))
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to read image"))
+		return
+	}
+	if int64(len(data)) > c.maxBytes {
+		c.abortTooLarge(ctx)
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, supported := imaging.ContentTypes[contentType]
+	if !supported {
+		apperror.Abort(ctx, apperror.New(http.StatusUnsupportedMediaType, apperror.CodeUnsupportedMediaType, "Images must be JPEG, PNG, GIF or WebP"))
+		return
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		appErr := apperror.BadRequest("The request is invalid").Wrap(err)
+		appErr.Details = []apperror.FieldError{{Field: "image", Rule: "image", Message: "could not be decoded or is too large"}}
+		apperror.Abort(ctx, appErr)
+		return
+	}
+
+	var thumbnail bytes.Buffer
+	thumbnailType, err := imaging.Encode(&thumbnail, imaging.Thumbnail(img, c.thumbnailSize), contentType)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to create thumbnail"))
+		return
+	}
+
+	base := fmt.Sprintf("products/%d/%s", product.Id, strings.ToLower(rand.Text()))
+	image := models.ProductImage{
+		ProductId:    product.Id,
+		Key:          base + ext,
+		ThumbnailKey: base + "_thumb" + imaging.ContentTypes[thumbnailType],
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        img.Bounds().Dx(),
+		Height:       img.Bounds().Dy(),
+	}
+
+	reqCtx := ctx.Request.Context()
+	if err := c.store.Put(reqCtx, image.Key, bytes.NewReader(data), image.Size, contentType); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to store image"))
+		return
+	}
+	if err := c.store.Put(reqCtx, image.ThumbnailKey, &thumbnail, int64(thumbnail.Len()), thumbnailType); err != nil {
+		c.deleteBlobs(ctx, image.Key)
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to store image"))
+		return
+	}
+
+	if err := c.imageRepo.CreateImage(reqCtx, &image); err != nil {
+		c.deleteBlobs(ctx, image.Key, image.ThumbnailKey)
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to save image"))
+		return
+	}
+
+	image.URL, image.ThumbnailURL = c.store.URL(image.Key), c.store.URL(image.ThumbnailKey)
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Image uploaded successfully", "image": image})
+}
+
+func (c *ImageController) DeleteImage(ctx *gin.Context) {
+	image, ok := c.loadOwnedImage(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.imageRepo.DeleteImage(ctx.Request.Context(), image); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to delete image"))
+		return
+	}
+	c.deleteBlobs(ctx, image.Key, image.ThumbnailKey)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
+
+// SetPrimaryImage makes the image the one shown first for the product.
+func (c *ImageController) SetPrimaryImage(ctx *gin.Context) {
+	image, ok := c.loadOwnedImage(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.imageRepo.SetPrimaryImage(ctx.Request.Context(), image.ProductId, image.Id); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to update image"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Primary image updated successfully"})
+}
+
+// ReorderImages sets the display order of all of a product's images.
+func (c *ImageController) ReorderImages(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+	var req models.ImageOrderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return
+	}
+
+	if err := c.imageRepo.ReorderImages(ctx.Request.Context(), product.Id, req.ImageIds); err != nil {
+		if errors.Is(err, repositories.ErrImageOrderMismatch) {
+			appErr := apperror.BadRequest("The request is invalid")
+			appErr.Details = []apperror.FieldError{{Field: "image_ids", Rule: "order", Message: "must list every image of the product exactly once"}}
+			apperror.Abort(ctx, appErr)
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to reorder images"))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Images reordered successfully"})
+}
+
+func (c *ImageController) loadOwnedImage(ctx *gin.Context) (*models.ProductImage, bool) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return nil, false
+	}
+	imageId, err := strconv.ParseInt(ctx.Param("imageId"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid image ID"))
+		return nil, false
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return nil, false
+	}
+
+	image, err := c.imageRepo.UsePrimary().GetImage(ctx.Request.Context(), product.Id, imageId)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeImageNotFound, "Image not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve image"))
+		}
+		return nil, false
+	}
+	return image, true
+}
+
+func (c *ImageController) abortTooLarge(ctx *gin.Context) {
+	apperror.Abort(ctx, apperror.New(http.StatusRequestEntityTooLarge, apperror.CodePayloadTooLarge,
+		fmt.Sprintf("Images must be at most %d bytes", c.maxBytes)))
+}
+
+// deleteBlobs removes files whose database row is gone or was never written.
+// Failures only leave an orphaned file behind, so they are logged.
+func (c *ImageController) deleteBlobs(ctx *gin.Context, keys ...string) {
+	for _, key := range keys {
+		if err := c.store.Delete(ctx.Request.Context(), key); err != nil {
+			slog.ErrorContext(ctx.Request.Context(), "Failed to delete image file", "key", key, "error", err)
+		}
+	}
+}
+
+// fillImageURLs sets the download URLs of images from their blob keys.
+func fillImageURLs(store storage.BlobStore, images []models.ProductImage) {
+	for i := range images {
+		images[i].URL = store.URL(images[i].Key)
+		images[i].ThumbnailURL = store.URL(images[i].ThumbnailKey)
+	}
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/util"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InventoryController lets merchants restock and correct their stock and
+// read its history in the inventory ledger.
+type InventoryController struct {
+	productRepo   repositories.ProductRepository
+	inventoryRepo repositories.InventoryRepository
+}
+
+func NewInventoryController(productRepo repositories.ProductRepository, inventoryRepo repositories.InventoryRepository) *InventoryController {
+	return &InventoryController{productRepo: productRepo, inventoryRepo: inventoryRepo}
+}
+
+func (c *InventoryController) AdjustStock(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+	var req models.InventoryAdjustmentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+	if req.Reason == models.MovementRestock && req.Delta < 0 {
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = []apperror.FieldError{{Field: "delta", Rule: "gt", Message: "must be positive for a restock"}}
+		apperror.Abort(ctx, appErr)
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return
+	}
+
+	// Products with variants keep their stock on the variants.
+	if req.VariantId == nil && len(product.Variants) > 0 {
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = []apperror.FieldError{{Field: "variant_id", Rule: "required", Message: "is required for products with variants"}}
+		apperror.Abort(ctx, appErr)
+		return
+	}
+	if req.VariantId != nil && !hasVariant(product, *req.VariantId) {
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeVariantNotFound, "Variant not found"))
+		return
+	}
+
+	movement := models.InventoryMovement{
+		ProductId: product.Id,
+		VariantId: req.VariantId,
+		Reason:    req.Reason,
+		Delta:     req.Delta,
+		Note:      req.Note,
+	}
+	if err := c.inventoryRepo.AdjustStock(ctx.Request.Context(), &movement); err != nil {
+		if errors.Is(err, repositories.ErrInsufficientStock) {
+			apperror.Abort(ctx, apperror.New(http.StatusBadRequest, apperror.CodeInsufficientStock, "The adjustment would leave negative stock"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to adjust stock"))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Stock adjusted successfully", "movement": movement})
+}
+
+func (c *InventoryController) ListMovements(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+	var req models.InventoryMovementQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return
+	}
+
+	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
+
+	movements, totalRecords, err := c.inventoryRepo.ListMovements(ctx.Request.Context(), product.Id, req)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list inventory movements"))
+		return
+	}
+	if movements == nil {
+		movements = []models.InventoryMovement{}
+	}
+
+	ctx.JSON(http.StatusOK, models.PaginatedInventoryMovementResponse{
+		Movements:    movements,
+		TotalRecords: totalRecords,
+		CurrentPage:  req.Page,
+		PageSize:     req.Limit,
+		TotalPages:   util.CalculateTotalPages(totalRecords, req.Limit),
+	})
+}
+
+func hasVariant(product *models.Product, variantId int64) bool {
+	for _, variant := range product.Variants {
+		if variant.Id == variantId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/util"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// NotificationController serves the in-app inbox, notification preferences
+// and customers' back-in-stock subscriptions.
+type NotificationController struct {
+	notificationRepo repositories.NotificationRepository
+	productRepo      repositories.ProductRepository
+}
+
+func NewNotificationController(notificationRepo repositories.NotificationRepository, productRepo repositories.ProductRepository) *NotificationController {
+	return &NotificationController{notificationRepo: notificationRepo, productRepo: productRepo}
+}
+
+func (c *NotificationController) ListNotifications(ctx *gin.Context) {
+	var req models.NotificationQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
+
+	notifications, totalRecords, unread, err := c.notificationRepo.ListNotifications(ctx.Request.Context(), ctx.GetInt64("user_id"), req)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list notifications"))
+		return
+	}
+	if notifications == nil {
+		notifications = []models.Notification{}
+	}
+
+	ctx.JSON(http.StatusOK, models.PaginatedNotificationResponse{
+		Notifications: notifications,
+		TotalRecords:  totalRecords,
+		Unread:        unread,
+		CurrentPage:   req.Page,
+		PageSize:      req.Limit,
+		TotalPages:    util.CalculateTotalPages(totalRecords, req.Limit),
+	})
+}
+
+func (c *NotificationController) MarkRead(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid notification ID"))
+		return
+	}
+
+	if err := c.notificationRepo.MarkRead(ctx.Request.Context(), ctx.GetInt64("user_id"), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeNotificationNotFound, "Notification not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to update notification"))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+func (c *NotificationController) MarkAllRead(ctx *gin.Context) {
+	if err := c.notificationRepo.MarkRead(ctx.Request.Context(), ctx.GetInt64("user_id"), 0); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to update notifications"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
+}
+
+func (c *NotificationController) GetPreferences(ctx *gin.Context) {
+	preference, err := c.notificationRepo.GetPreference(ctx.Request.Context(), ctx.GetInt64("user_id"))
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve notification preferences"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, preference)
+}
+
+func (c *NotificationController) UpdatePreferences(ctx *gin.Context) {
+	var req models.NotificationPreferenceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	preference := models.NotificationPreference{
+		UserId:       ctx.GetInt64("user_id"),
+		EmailEnabled: *req.EmailEnabled,
+		WebhookUrl:   req.WebhookUrl,
+	}
+	if err := c.notificationRepo.SavePreference(ctx.Request.Context(), &preference); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to save notification preferences"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Notification preferences updated successfully", "preferences": preference})
+}
+
+// SubscribeBackInStock asks for a notification once a sold-out product, or
+// the variant in the body, can be bought again. The body may be empty for
+// products without variants.
+func (c *NotificationController) SubscribeBackInStock(ctx *gin.Context) {
+	var req models.StockSubscriptionRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			apperror.Abort(ctx, apperror.Validation(err))
+			return
+		}
+	}
+
+	product, available, ok := c.loadStockItem(ctx, req.VariantId)
+	if !ok {
+		return
+	}
+	if available > 0 {
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeInStock, "The product is in stock"))
+		return
+	}
+
+	subscription := models.StockSubscription{
+		CustomerId: ctx.GetInt64("user_id"),
+		ProductId:  product.Id,
+		VariantId:  req.VariantId,
+	}
+	if err := c.notificationRepo.Subscribe(ctx.Request.Context(), &subscription); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to subscribe"))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "You will be notified when the product is back in stock"})
+}
+
+// UnsubscribeBackInStock removes the subscription to the product, or to the
+// variant_id query parameter.
+func (c *NotificationController) UnsubscribeBackInStock(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+	var req models.StockSubscriptionRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	err = c.notificationRepo.Unsubscribe(ctx.Request.Context(), ctx.GetInt64("user_id"), productId, req.VariantId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeSubscriptionNotFound, "Subscription not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to unsubscribe"))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Unsubscribed successfully"})
+}
+
+// loadStockItem resolves the :id parameter and variantId to something a
+// customer can buy and returns how many are available. As at checkout,
+// products with variants are stocked per variant.
+func (c *NotificationController) loadStockItem(ctx *gin.Context, variantId int64) (*models.ProductDetail, int64, bool) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return nil, 0, false
+	}
+
+	product, err := c.productRepo.UsePrimary().GetProductByID(ctx.Request.Context(), productId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Product not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve product"))
+		}
+		return nil, 0, false
+	}
+
+	if variantId == 0 {
+		if len(product.Variants) > 0 {
+			appErr := apperror.BadRequest("The request is invalid")
+			appErr.Details = []apperror.FieldError{{Field: "variant_id", Rule: "required", Message: "is required for products with variants"}}
+			apperror.Abort(ctx, appErr)
+			return nil, 0, false
+		}
+		return product, product.Quantity, true
+	}
+	for _, variant := range product.Variants {
+		if variant.Id == variantId {
+			return product, variant.Quantity, true
+		}
+	}
+	apperror.Abort(ctx, apperror.NotFound(apperror.CodeVariantNotFound, "Variant not found"))
+	return nil, 0, false
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// decodeCursor reads the cursor parameter of a listing. The scope names the
+// listing and whatever shapes its order, so a token only works where it was
+// issued. It reports false after aborting the request.
+func decodeCursor(ctx *gin.Context, signer *util.CursorSigner, scope, token string) (*models.Cursor, bool) {
+	if token == "" {
+		return nil, true
+	}
+	var cursor models.Cursor
+	if err := signer.Decode(scope, token, &cursor); err != nil {
+		abortInvalidCursor(ctx, err)
+		return nil, false
+	}
+	return &cursor, true
+}
+
+func abortInvalidCursor(ctx *gin.Context, err error) {
+	appErr := apperror.BadRequest("The request is invalid").Wrap(err)
+	appErr.Details = []apperror.FieldError{{Field: "cursor", Rule: "cursor", Message: "is not a valid cursor for this listing"}}
+	apperror.Abort(ctx, appErr)
+}
+
+// pageLinks turns the cursors of page into tokens for the same scope and
+// links to the neighbouring pages.
+func pageLinks[T any](ctx *gin.Context, signer *util.CursorSigner, scope string, page *models.Page[T]) (models.PageLinks, error) {
+	var links models.PageLinks
+	if page.Next != nil {
+		token, err := signer.Encode(scope, page.Next)
+		if err != nil {
+			return links, err
+		}
+		links.NextCursor, links.Next = token, util.PageLink(ctx, token)
+	}
+	if page.Prev != nil {
+		token, err := signer.Encode(scope, page.Prev)
+		if err != nil {
+			return links, err
+		}
+		links.PrevCursor, links.Prev = token, util.PageLink(ctx, token)
+	}
+	return links, nil
+}
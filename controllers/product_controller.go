@@ -1,44 +1,79 @@
 package controllers
 
 import (
+	"backend-hanssen-hilman/apperror"
 	"backend-hanssen-hilman/models"
 	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/search"
+	"backend-hanssen-hilman/storage"
 	"backend-hanssen-hilman/util"
+	"cmp"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
 )
 
 type ProductController struct {
-	productRepo repositories.ProductRepository
+	productRepo  repositories.ProductRepository
+	categoryRepo repositories.CategoryRepository
+	store        storage.BlobStore
+	index        search.SearchIndex
+	cursors      *util.CursorSigner
 }
 
-func NewProductController(productRepo repositories.ProductRepository) *ProductController {
-	return &ProductController{productRepo: productRepo}
+// merchantProductsScope binds cursors to the merchant's own product listing.
+const merchantProductsScope = "merchant_products"
+
+// descriptionSnippetLength is roughly how many characters of a description
+// a search highlight shows.
+const descriptionSnippetLength = 160
+
+func NewProductController(productRepo repositories.ProductRepository, categoryRepo repositories.CategoryRepository, store storage.BlobStore, index search.SearchIndex, cursors *util.CursorSigner) *ProductController {
+	return &ProductController{productRepo: productRepo, categoryRepo: categoryRepo, store: store, index: index, cursors: cursors}
 }
 
 func (c *ProductController) CreateProduct(ctx *gin.Context) {
-	var req models.ProductRequest
+	var req models.ProductCreateRequest
 	merchantId := ctx.GetInt64("user_id")
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	categories, ok := c.resolveCategories(ctx, req.CategoryIds)
+	if !ok {
+		return
+	}
+	sku, ok := c.checkSku(ctx, merchantId, req.Sku, 0)
+	if !ok {
 		return
 	}
 
 	newProduct := models.Product{
+		Sku:         sku,
 		Name:        req.Name,
 		Description: req.Description,
-		Price:       req.Price,
+		Price:       *req.Price,
 		MerchantId:  merchantId,
 		Quantity:    req.Quantity,
+		Options:     req.Options,
+		Categories:  categories,
+
+		LowStockThreshold: req.LowStockThreshold,
 	}
 
-	if err := c.productRepo.CreateProduct(&newProduct); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+	if err := c.productRepo.CreateProduct(ctx.Request.Context(), &newProduct); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to create product"))
 		return
 	}
+	c.index.Index(searchDocument(&newProduct))
 
 	ctx.JSON(http.StatusCreated, gin.H{"message": "Product created successfully", "product": newProduct})
 
@@ -47,52 +82,87 @@ func (c *ProductController) CreateProduct(ctx *gin.Context) {
 func (c *ProductController) GetProductByID(ctx *gin.Context) {
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
 		return
 	}
 
-	product, err := c.productRepo.GetProductByID(id)
+	product, err := c.productRepo.GetProductByID(ctx.Request.Context(), id)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Product not found"))
 		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product"})
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve product"))
 		}
 		return
 	}
 
+	etag := productETag(&product.Product)
+	ctx.Header("ETag", etag)
+	if match := ctx.GetHeader("If-None-Match"); match != "" && util.ETagMatchesWeak(match, etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	fillImageURLs(c.store, product.Images)
 	ctx.JSON(http.StatusOK, product)
 
 }
 
 func (c *ProductController) GetProductsByMerchantID(ctx *gin.Context) {
-	var req models.ProductRequest
+	var req models.ProductQuery
 	merchantId := ctx.GetInt64("user_id")
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"invalid query parameters: ": err.Error()})
+		apperror.Abort(ctx, apperror.Validation(err))
 		return
 	}
 
 	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
 
-	products, totalRecords, err := c.productRepo.GetProductByMerchantID(merchantId, req.Page, req.Limit)
+	cursor, ok := decodeCursor(ctx, c.cursors, merchantProductsScope, req.Cursor)
+	if !ok {
+		return
+	}
+	if cursor != nil {
+		req.Page = 0
+	}
+
+	page, err := c.productRepo.GetProductByMerchantID(ctx.Request.Context(), merchantId, cursor, req.Page, req.Limit)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list products"})
+		if errors.Is(err, repositories.ErrInvalidCursor) {
+			abortInvalidCursor(ctx, err)
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to list products"))
+		}
+		return
+	}
+	links, err := pageLinks(ctx, c.cursors, merchantProductsScope, page)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list products"))
 		return
 	}
 
+	totalRecords := page.Total
 	totalPages := util.CalculateTotalPages(totalRecords, req.Limit)
 
 	productResponses := []models.ProductResponse{}
 
-	for _, p := range products {
+	for _, p := range page.Items {
+		fillImageURLs(c.store, p.Product.Images)
 		productRes := models.ProductResponse{
 			Id:           p.Product.Id,
+			Sku:          p.Product.Sku,
 			Name:         p.Product.Name,
 			Description:  p.Product.Description,
 			Price:        p.Product.Price,
 			MerchantName: p.MerchantName,
 			Quantity:     p.Product.Quantity,
+			Archived:     p.Product.Archived,
+			Options:      p.Product.Options,
+			Variants:     p.Product.Variants,
+			Images:       p.Product.Images,
+			Categories:   p.Product.Categories,
+			RatingAvg:    p.Product.RatingAvg,
+			RatingCount:  p.Product.RatingCount,
 		}
 
 		productResponses = append(productResponses, productRes)
@@ -104,81 +174,453 @@ func (c *ProductController) GetProductsByMerchantID(ctx *gin.Context) {
 		CurrentPage:  req.Page,
 		PageSize:     req.Limit,
 		TotalPages:   totalPages,
+		Links:        links,
 	})
 }
 
+// UpdateProduct replaces every editable field of the product (PUT).
 func (c *ProductController) UpdateProduct(ctx *gin.Context) {
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
 		return
 	}
-	var req models.ProductRequest
+	var req models.ProductReplaceRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, id)
+	if !ok || !checkIfMatch(ctx, product) {
+		return
+	}
+
+	c.saveProduct(ctx, product, req)
+}
+
+// PatchProduct applies a JSON Merge Patch (RFC 7396) to the product. Members
+// that are absent stay unchanged, null clears the description, and the
+// patched product must still pass the same rules as a PUT.
+func (c *ProductController) PatchProduct(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+	var patch models.ProductPatchRequest
+	if err := ctx.ShouldBindJSON(&patch); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, id)
+	if !ok || !checkIfMatch(ctx, product) {
+		return
+	}
+
+	req := models.ProductReplaceRequest{
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       &product.Price,
+		Quantity:    &product.Quantity,
+		Options:     product.Options,
+
+		LowStockThreshold: product.LowStockThreshold,
+	}
+	if product.Sku != nil {
+		req.Sku = *product.Sku
+	}
+	for _, category := range product.Categories {
+		req.CategoryIds = append(req.CategoryIds, category.Id)
+	}
+
+	var details []apperror.FieldError
+	if patch.Sku.Set {
+		req.Sku = patch.Sku.Value
+	}
+	if patch.Name.Set {
+		if patch.Name.Null {
+			details = append(details, apperror.FieldError{Field: "name", Rule: "required", Message: "cannot be removed"})
+		}
+		req.Name = patch.Name.Value
+	}
+	if patch.Description.Set {
+		req.Description = patch.Description.Value
+	}
+	if patch.Price.Set {
+		if patch.Price.Null {
+			details = append(details, apperror.FieldError{Field: "price", Rule: "required", Message: "cannot be removed"})
+		}
+		req.Price = &patch.Price.Value
+	}
+	if patch.Quantity.Set {
+		if patch.Quantity.Null {
+			details = append(details, apperror.FieldError{Field: "quantity", Rule: "required", Message: "cannot be removed"})
+		}
+		req.Quantity = &patch.Quantity.Value
+	}
+	if patch.Options.Set {
+		req.Options = patch.Options.Value
+	}
+	if patch.CategoryIds.Set {
+		req.CategoryIds = patch.CategoryIds.Value
+	}
+	if patch.LowStockThreshold.Set {
+		req.LowStockThreshold = nil
+		if !patch.LowStockThreshold.Null {
+			req.LowStockThreshold = &patch.LowStockThreshold.Value
+		}
+	}
+	if len(details) > 0 {
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = details
+		apperror.Abort(ctx, appErr)
+		return
+	}
+
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
 		return
 	}
 
-	product, err := c.productRepo.GetProductByID(id)
+	c.saveProduct(ctx, product, req)
+}
+
+// loadOwnedProduct fetches the product from the primary and checks that it
+// belongs to the calling merchant. Other merchants' products are reported as
+// not found so their IDs can't be probed.
+func loadOwnedProduct(ctx *gin.Context, productRepo repositories.ProductRepository, id int64) (*models.Product, bool) {
+	product, err := productRepo.UsePrimary().GetProductByID(ctx.Request.Context(), id)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Product not found"))
 		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product"})
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve product"))
 		}
-		return
+		return nil, false
 	}
 
-	productToUpdate := product.Product
+	if product.MerchantId != ctx.GetInt64("user_id") {
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Product not found"))
+		return nil, false
+	}
+
+	return &product.Product, true
+}
 
-	if req.Name != "" {
-		productToUpdate.Name = req.Name
+// resolveCategories loads the categories a product is being linked to and
+// rejects the request if any of them doesn't exist.
+func (c *ProductController) resolveCategories(ctx *gin.Context, ids []int64) ([]models.Category, bool) {
+	unique := make([]int64, 0, len(ids))
+	seen := map[int64]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
 	}
-	if req.Description != "" {
-		productToUpdate.Description = req.Description
+
+	categories, err := c.categoryRepo.UsePrimary().GetCategoriesByIDs(ctx.Request.Context(), unique)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve categories"))
+		return nil, false
 	}
-	if req.Price > 0 {
-		productToUpdate.Price = req.Price
+	if len(categories) != len(unique) {
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = []apperror.FieldError{{Field: "category_ids", Rule: "exists", Message: "contains an unknown category"}}
+		apperror.Abort(ctx, appErr)
+		return nil, false
 	}
-	if req.Quantity >= 0 {
-		productToUpdate.Quantity = req.Quantity
+	return categories, true
+}
+
+// checkSku makes sure no other product of the merchant, including deleted
+// ones, uses sku. It returns the value to store, nil for no SKU.
+func (c *ProductController) checkSku(ctx *gin.Context, merchantId int64, sku string, exceptId int64) (*string, bool) {
+	if sku == "" {
+		return nil, true
 	}
+	taken, err := c.productRepo.UsePrimary().SkuTaken(ctx.Request.Context(), merchantId, sku, exceptId)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to check SKU"))
+		return nil, false
+	}
+	if taken {
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeSkuTaken, "Another product already uses this SKU"))
+		return nil, false
+	}
+	return &sku, true
+}
 
-	if err := c.productRepo.UpdateProduct(&productToUpdate); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+// productETag tags everything the product's representation shows. Variants,
+// images, categories and the rating change without the product's version,
+// so they are folded in too, in ID order since they aren't always loaded in
+// the same order.
+func productETag(product *models.Product) string {
+	variants := slices.SortedFunc(slices.Values(product.Variants), func(a, b models.ProductVariant) int { return cmp.Compare(a.Id, b.Id) })
+	images := slices.SortedFunc(slices.Values(product.Images), func(a, b models.ProductImage) int { return cmp.Compare(a.Id, b.Id) })
+	categories := slices.SortedFunc(slices.Values(product.Categories), func(a, b models.Category) int { return cmp.Compare(a.Id, b.Id) })
+
+	parts := []any{product.RatingAvg, product.RatingCount}
+	for _, variant := range variants {
+		parts = append(parts, "variant", variant.Id, variant.Version)
+	}
+	for _, image := range images {
+		parts = append(parts, "image", image.Id, image.Position, image.IsPrimary)
+	}
+	for _, category := range categories {
+		parts = append(parts, "category", category.Id, category.UpdatedAt.UnixNano())
+	}
+	return util.ETag("product", product.Id, product.Version, parts...)
+}
+
+// checkIfMatch enforces the If-Match precondition on writes so that a client
+// can't overwrite changes it hasn't seen.
+func checkIfMatch(ctx *gin.Context, product *models.Product) bool {
+	match := ctx.GetHeader("If-Match")
+	if match == "" {
+		apperror.Abort(ctx, apperror.New(http.StatusPreconditionRequired, apperror.CodePreconditionNeeded, "If-Match header is required"))
+		return false
+	}
+	if !util.ETagMatchesStrong(match, productETag(product)) {
+		abortPreconditionFailed(ctx)
+		return false
+	}
+	return true
+}
+
+func abortPreconditionFailed(ctx *gin.Context) {
+	apperror.Abort(ctx, apperror.New(http.StatusPreconditionFailed, apperror.CodePreconditionFailed, "Product was modified by another request"))
+}
+
+func (c *ProductController) saveProduct(ctx *gin.Context, product *models.Product, req models.ProductReplaceRequest) {
+	// Existing variants are keyed by the option axes, so those can only
+	// change once the variants are gone.
+	if len(product.Variants) > 0 && !slices.Equal(product.Options, req.Options) {
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = []apperror.FieldError{{Field: "options", Rule: "variants", Message: "can't change while the product has variants"}}
+		apperror.Abort(ctx, appErr)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Product updated successfully"})
+	categories, ok := c.resolveCategories(ctx, req.CategoryIds)
+	if !ok {
+		return
+	}
+	sku, ok := c.checkSku(ctx, product.MerchantId, req.Sku, product.Id)
+	if !ok {
+		return
+	}
+
+	product.Sku = sku
+	product.Categories = categories
+	product.Options = req.Options
+	product.Name = req.Name
+	product.Description = req.Description
+	product.Price = *req.Price
+	product.Quantity = *req.Quantity
+	product.LowStockThreshold = req.LowStockThreshold
+
+	if err := c.productRepo.UpdateProduct(ctx.Request.Context(), product); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			abortPreconditionFailed(ctx)
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to update product"))
+		}
+		return
+	}
+	c.index.Index(searchDocument(product))
+
+	fillImageURLs(c.store, product.Images)
+	ctx.Header("ETag", productETag(product))
+	ctx.JSON(http.StatusOK, gin.H{"message": "Product updated successfully", "product": product})
 }
 
 func (c *ProductController) DeleteProduct(ctx *gin.Context) {
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, id)
+	if !ok || !checkIfMatch(ctx, product) {
 		return
 	}
 
-	if err := c.productRepo.DeleteProduct(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+	if err := c.productRepo.DeleteProduct(ctx.Request.Context(), product); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			abortPreconditionFailed(ctx)
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to delete product"))
+		}
 		return
 	}
+	c.index.Remove(product.Id)
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 }
 
+// ArchiveProduct unlists the product from the catalog without deleting it.
+func (c *ProductController) ArchiveProduct(ctx *gin.Context) {
+	c.setArchived(ctx, true)
+}
+
+// UnarchiveProduct lists an archived product in the catalog again.
+func (c *ProductController) UnarchiveProduct(ctx *gin.Context) {
+	c.setArchived(ctx, false)
+}
+
+func (c *ProductController) setArchived(ctx *gin.Context, archived bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, id)
+	if !ok || !checkIfMatch(ctx, product) {
+		return
+	}
+
+	if err := c.productRepo.SetArchived(ctx.Request.Context(), product, archived); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			abortPreconditionFailed(ctx)
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to update product"))
+		}
+		return
+	}
+
+	fillImageURLs(c.store, product.Images)
+	ctx.Header("ETag", productETag(product))
+	ctx.JSON(http.StatusOK, gin.H{"message": "Product updated successfully", "product": product})
+}
+
+// RestoreProduct undoes the soft deletion of one of the merchant's products.
+func (c *ProductController) RestoreProduct(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+
+	if err := c.productRepo.RestoreProduct(ctx.Request.Context(), ctx.GetInt64("user_id"), id); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Deleted product not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to restore product"))
+		}
+		return
+	}
+
+	// A failure here only delays the product in search until the next
+	// rebuild of the index.
+	if product, err := c.productRepo.UsePrimary().GetProductByID(ctx.Request.Context(), id); err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "Failed to index restored product", "product_id", id, "error", err)
+	} else {
+		c.index.Index(searchDocument(&product.Product))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Product restored successfully"})
+}
+
+func (c *ProductController) ListDeletedProducts(ctx *gin.Context) {
+	var req models.ProductQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
+
+	products, totalRecords, err := c.productRepo.UsePrimary().ListDeletedProducts(ctx.Request.Context(), ctx.GetInt64("user_id"), req.Page, req.Limit)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list products"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"products":      products,
+		"total_records": totalRecords,
+		"current_page":  req.Page,
+		"page_size":     req.Limit,
+		"total_pages":   util.CalculateTotalPages(totalRecords, req.Limit),
+	})
+}
+
 func (c *ProductController) ListProducts(ctx *gin.Context) {
-	var req models.ProductRequest
+	var req models.ProductQuery
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"invalid query parameters: ": err.Error()})
+		apperror.Abort(ctx, apperror.Validation(err))
 		return
 	}
 
 	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
 
-	products, totalRecords, err := c.productRepo.ListProducts(req)
+	if req.Category != "" {
+		categories, err := c.categoryRepo.ListCategories(ctx.Request.Context())
+		if err != nil {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to list products"))
+			return
+		}
+		// An unknown slug leaves the filter empty, which matches nothing.
+		req.CategoryIds = []int64{}
+		for _, category := range categories {
+			if category.Slug == req.Category {
+				req.CategoryIds = repositories.DescendantIDs(categories, category.Id)
+				break
+			}
+		}
+	}
+
+	// terms holds the indexed words each search hit matched, for highlighting.
+	var terms map[int64][]string
+	if len(search.Tokenize(req.Q)) > 0 {
+		// The repository caps the hits once the other filters have been
+		// applied, so every hit is passed on.
+		hits := c.index.Search(req.Q, math.MaxInt)
+		terms = make(map[int64][]string, len(hits))
+		req.SearchIds = make([]int64, 0, len(hits))
+		for _, hit := range hits {
+			req.SearchIds = append(req.SearchIds, hit.ID)
+			terms[hit.ID] = hit.Terms
+		}
+	}
+
+	// Searches without an explicit sort are ordered by relevance to q.
+	scope := "products:" + req.Sort
+	if req.SearchIds != nil {
+		scope += ":" + req.Q
+	}
+	cursor, ok := decodeCursor(ctx, c.cursors, scope, req.Cursor)
+	if !ok {
+		return
+	}
+	if cursor != nil {
+		req.Page, req.Position = 0, cursor
+	}
+
+	page, err := c.productRepo.ListProducts(ctx.Request.Context(), req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list products"})
+		if errors.Is(err, repositories.ErrInvalidCursor) {
+			abortInvalidCursor(ctx, err)
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to list products"))
+		}
+		return
+	}
+	links, err := pageLinks(ctx, c.cursors, scope, page)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list products"))
+		return
+	}
+	products, totalRecords := page.Items, page.Total
+
+	facets, err := c.productRepo.ProductFacets(ctx.Request.Context(), req)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list products"))
 		return
 	}
 
@@ -187,13 +629,27 @@ func (c *ProductController) ListProducts(ctx *gin.Context) {
 	productResponses := []models.ProductResponse{}
 
 	for _, p := range products {
+		fillImageURLs(c.store, p.Images)
 		productRes := models.ProductResponse{
 			Id:           p.Id,
+			Sku:          p.Sku,
 			Name:         p.Name,
 			Description:  p.Description,
 			Price:        p.Price,
 			MerchantName: p.MerchantName,
 			Quantity:     p.Quantity,
+			Options:      p.Options,
+			Variants:     p.Variants,
+			Images:       p.Images,
+			Categories:   p.Categories,
+			RatingAvg:    p.RatingAvg,
+			RatingCount:  p.RatingCount,
+		}
+		if terms != nil {
+			productRes.Highlights = &models.SearchHighlights{
+				Name:        search.Snippet(p.Name, terms[p.Id], len(p.Name)),
+				Description: search.Snippet(p.Description, terms[p.Id], descriptionSnippetLength),
+			}
 		}
 
 		productResponses = append(productResponses, productRes)
@@ -205,5 +661,11 @@ func (c *ProductController) ListProducts(ctx *gin.Context) {
 		CurrentPage:  req.Page,
 		PageSize:     req.Limit,
 		TotalPages:   totalPages,
+		Links:        links,
+		Facets:       facets,
 	})
 }
+
+func searchDocument(product *models.Product) search.Document {
+	return search.Document{ID: product.Id, Name: product.Name, Description: product.Description}
+}
@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/util"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReviewController struct {
+	reviewRepo  repositories.ReviewRepository
+	productRepo repositories.ProductRepository
+}
+
+func NewReviewController(reviewRepo repositories.ReviewRepository, productRepo repositories.ProductRepository) *ReviewController {
+	return &ReviewController{reviewRepo: reviewRepo, productRepo: productRepo}
+}
+
+// ListReviews lists a product's published reviews for customers.
+func (c *ReviewController) ListReviews(ctx *gin.Context) {
+	product, ok := c.loadProduct(ctx)
+	if !ok {
+		return
+	}
+	c.listReviews(ctx, product)
+}
+
+// ListMerchantReviews lists the reviews of one of the merchant's products.
+func (c *ReviewController) ListMerchantReviews(ctx *gin.Context) {
+	productId, ok := idParam(ctx, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return
+	}
+	c.listReviews(ctx, product)
+}
+
+func (c *ReviewController) listReviews(ctx *gin.Context, product *models.Product) {
+	var req models.ReviewQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
+
+	reviews, totalRecords, err := c.reviewRepo.ListReviews(ctx.Request.Context(), product.Id, req)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list reviews"))
+		return
+	}
+	if reviews == nil {
+		reviews = []models.ReviewDetail{}
+	}
+
+	ctx.JSON(http.StatusOK, models.PaginatedReviewResponse{
+		Reviews:      reviews,
+		RatingAvg:    product.RatingAvg,
+		RatingCount:  product.RatingCount,
+		TotalRecords: totalRecords,
+		CurrentPage:  req.Page,
+		PageSize:     req.Limit,
+		TotalPages:   util.CalculateTotalPages(totalRecords, req.Limit),
+	})
+}
+
+// CreateReview lets a customer who bought the product review it once.
+func (c *ReviewController) CreateReview(ctx *gin.Context) {
+	var req models.ReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	product, ok := c.loadProduct(ctx)
+	if !ok {
+		return
+	}
+
+	review := models.Review{
+		ProductId:  product.Id,
+		CustomerId: ctx.GetInt64("user_id"),
+		Rating:     req.Rating,
+		Body:       req.Body,
+	}
+	if err := c.reviewRepo.CreateReview(ctx.Request.Context(), &review); err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrNotVerifiedBuyer):
+			apperror.Abort(ctx, apperror.New(http.StatusForbidden, apperror.CodeNotVerifiedBuyer, "Only customers who bought the product can review it"))
+		case errors.Is(err, repositories.ErrReviewExists):
+			apperror.Abort(ctx, apperror.Conflict(apperror.CodeReviewExists, "You have already reviewed this product"))
+		default:
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to create review"))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Review created successfully", "review": review})
+}
+
+func (c *ReviewController) UpdateReview(ctx *gin.Context) {
+	productId, ok := idParam(ctx, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+	reviewId, ok := idParam(ctx, "reviewId", "Invalid review ID")
+	if !ok {
+		return
+	}
+	var req models.ReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	review := models.Review{
+		Id:         reviewId,
+		ProductId:  productId,
+		CustomerId: ctx.GetInt64("user_id"),
+		Rating:     req.Rating,
+		Body:       req.Body,
+	}
+	if err := c.reviewRepo.UpdateReview(ctx.Request.Context(), &review); err != nil {
+		abortReviewError(ctx, err, "Failed to update review")
+		return
+	}
+
+	updated, err := c.reviewRepo.UsePrimary().GetReview(ctx.Request.Context(), productId, reviewId)
+	if err != nil {
+		abortReviewError(ctx, err, "Failed to retrieve review")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Review updated successfully", "review": updated})
+}
+
+func (c *ReviewController) DeleteReview(ctx *gin.Context) {
+	productId, ok := idParam(ctx, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+	reviewId, ok := idParam(ctx, "reviewId", "Invalid review ID")
+	if !ok {
+		return
+	}
+
+	err := c.reviewRepo.DeleteReview(ctx.Request.Context(), ctx.GetInt64("user_id"), productId, reviewId)
+	if err != nil {
+		abortReviewError(ctx, err, "Failed to delete review")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+}
+
+// ReplyToReview sets the merchant's public reply, replacing any earlier one.
+func (c *ReviewController) ReplyToReview(ctx *gin.Context) {
+	productId, ok := idParam(ctx, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+	reviewId, ok := idParam(ctx, "reviewId", "Invalid review ID")
+	if !ok {
+		return
+	}
+	var req models.ReviewReplyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return
+	}
+
+	if err := c.reviewRepo.ReplyToReview(ctx.Request.Context(), product.Id, reviewId, req.Reply); err != nil {
+		abortReviewError(ctx, err, "Failed to reply to review")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Reply saved successfully"})
+}
+
+// FlagReview reports a review for moderation. Any signed-in user can flag.
+func (c *ReviewController) FlagReview(ctx *gin.Context) {
+	reviewId, ok := idParam(ctx, "id", "Invalid review ID")
+	if !ok {
+		return
+	}
+	var req models.ReviewFlagRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	flag := models.ReviewFlag{
+		ReviewId: reviewId,
+		UserId:   ctx.GetInt64("user_id"),
+		Reason:   req.Reason,
+		Note:     req.Note,
+	}
+	if err := c.reviewRepo.FlagReview(ctx.Request.Context(), &flag); err != nil {
+		abortReviewError(ctx, err, "Failed to flag review")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Review flagged for moderation"})
+}
+
+func (c *ReviewController) ListFlaggedReviews(ctx *gin.Context) {
+	var req models.ReviewQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
+
+	reviews, totalRecords, err := c.reviewRepo.ListFlaggedReviews(ctx.Request.Context(), req.Page, req.Limit)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list flagged reviews"))
+		return
+	}
+	if reviews == nil {
+		reviews = []models.FlaggedReview{}
+	}
+
+	ctx.JSON(http.StatusOK, models.PaginatedFlaggedReviewResponse{
+		Reviews:      reviews,
+		TotalRecords: totalRecords,
+		CurrentPage:  req.Page,
+		PageSize:     req.Limit,
+		TotalPages:   util.CalculateTotalPages(totalRecords, req.Limit),
+	})
+}
+
+// ModerateReview hides a review, or publishes it again, and resolves the
+// flags against it.
+func (c *ReviewController) ModerateReview(ctx *gin.Context) {
+	reviewId, ok := idParam(ctx, "id", "Invalid review ID")
+	if !ok {
+		return
+	}
+	var req models.ReviewModerationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	status := models.ReviewPublished
+	if req.Action == "hide" {
+		status = models.ReviewHidden
+	}
+	if err := c.reviewRepo.ModerateReview(ctx.Request.Context(), reviewId, status); err != nil {
+		abortReviewError(ctx, err, "Failed to moderate review")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Review moderated successfully", "status": status})
+}
+
+func (c *ReviewController) loadProduct(ctx *gin.Context) (*models.Product, bool) {
+	productId, ok := idParam(ctx, "id", "Invalid product ID")
+	if !ok {
+		return nil, false
+	}
+
+	product, err := c.productRepo.GetProductByID(ctx.Request.Context(), productId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Product not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve product"))
+		}
+		return nil, false
+	}
+	return &product.Product, true
+}
+
+// idParam parses the named path parameter as an ID, rejecting the request
+// with message if it isn't one.
+func idParam(ctx *gin.Context, name, message string) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param(name), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest(message))
+		return 0, false
+	}
+	return id, true
+}
+
+func abortReviewError(ctx *gin.Context, err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeReviewNotFound, "Review not found"))
+	} else {
+		apperror.Abort(ctx, apperror.Internal(err, message))
+	}
+}
@@ -1,145 +1,324 @@
 package controllers
 
 import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/metrics"
 	"backend-hanssen-hilman/models"
 	"backend-hanssen-hilman/repositories"
 	"backend-hanssen-hilman/util"
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type TransactionController struct {
-	transactionRepo repositories.TransactionRepository
-	productRepo     repositories.ProductRepository
+	transactionRepo      repositories.TransactionRepository
+	productRepo          repositories.ProductRepository
+	reservationTTL       time.Duration
+	readYourWritesWindow time.Duration
+	metrics              *metrics.Metrics
+	cursors              *util.CursorSigner
 }
 
-func NewTransactionController(transactionRepo repositories.TransactionRepository, productRepo repositories.ProductRepository) *TransactionController {
+func NewTransactionController(transactionRepo repositories.TransactionRepository, productRepo repositories.ProductRepository, reservationTTL, readYourWritesWindow time.Duration, metrics *metrics.Metrics, cursors *util.CursorSigner) *TransactionController {
 	return &TransactionController{
-		transactionRepo: transactionRepo,
-		productRepo:     productRepo,
+		transactionRepo:      transactionRepo,
+		productRepo:          productRepo,
+		reservationTTL:       reservationTTL,
+		readYourWritesWindow: readYourWritesWindow,
+		metrics:              metrics,
+		cursors:              cursors,
 	}
 }
 
+// Scopes that bind cursors to each transaction listing.
+const (
+	merchantTransactionsScope = "merchant_transactions"
+	customerTransactionsScope = "customer_transactions"
+)
+
+// transactionReader picks the replica-backed repository unless the caller has
+// to see its own recent writes.
+func (c *TransactionController) transactionReader(ctx *gin.Context) repositories.TransactionRepository {
+	if util.PrefersPrimary(ctx) {
+		return c.transactionRepo.UsePrimary()
+	}
+	return c.transactionRepo
+}
+
 func (c *TransactionController) CreateTransaction(ctx *gin.Context) {
 	var req models.TransactionRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.metrics.CheckoutFailed(metrics.ReasonInvalidRequest)
+		apperror.Abort(ctx, apperror.Validation(err))
 		return
 	}
 
 	customerId := ctx.GetInt64("user_id")
 
-	product, err := c.productRepo.GetProductByID(req.ProductId)
+	// Stock is checked against the primary; a lagging replica could oversell.
+	product, err := c.productRepo.UsePrimary().GetProductByID(ctx.Request.Context(), req.ProductId)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product"})
+		if err == gorm.ErrRecordNotFound {
+			c.metrics.CheckoutFailed(metrics.ReasonProductNotFound)
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Product not found"))
+		} else {
+			c.metrics.CheckoutFailed(metrics.ReasonInternalError)
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve product"))
+		}
 		return
 	}
 
-	if product.Product.Quantity < req.Quantity {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient product quantity"})
+	// Products with variants are priced and stocked per variant.
+	price := product.Product.Price
+	available := product.Product.Quantity
+	var variant *models.ProductVariant
+	if req.VariantId != nil {
+		for i := range product.Variants {
+			if product.Variants[i].Id == *req.VariantId {
+				variant = &product.Variants[i]
+			}
+		}
+		if variant == nil {
+			c.metrics.CheckoutFailed(metrics.ReasonVariantNotFound)
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeVariantNotFound, "Variant not found"))
+			return
+		}
+		price = variant.UnitPrice(price)
+		available = variant.Quantity
+	} else if len(product.Variants) > 0 {
+		c.metrics.CheckoutFailed(metrics.ReasonInvalidRequest)
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = []apperror.FieldError{{Field: "variant_id", Rule: "required", Message: "is required for products with variants"}}
+		apperror.Abort(ctx, appErr)
 		return
 	}
 
-	productToUpdate := product.Product
-
-	var totalPrice int64
-	deliveryFee := 5000
-
-	if product.Price < 15000 {
-		totalPrice = req.Quantity*int64(product.Product.Price) + int64(deliveryFee)
-	} else if product.Price > 50000 {
-		totalPrice = req.Quantity * int64(product.Product.Price-(product.Product.Price*10/100))
+	if available < req.Quantity {
+		c.metrics.CheckoutFailed(metrics.ReasonInsufficientStock)
+		apperror.Abort(ctx, apperror.New(http.StatusBadRequest, apperror.CodeInsufficientStock, "Insufficient product quantity"))
+		return
 	}
 
 	newTransaction := models.Transaction{
 		ProductId:  req.ProductId,
+		VariantId:  req.VariantId,
 		Quantity:   req.Quantity,
-		TotalPrice: float64(totalPrice),
+		TotalPrice: transactionTotal(price, req.Quantity),
 		CustomerId: customerId,
 	}
+	if variant != nil {
+		newTransaction.Sku = variant.Sku
+	}
 
-	if err := c.transactionRepo.CreateTransaction(&newTransaction); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
+	// The reservation is a single conditional UPDATE, so two checkouts
+	// racing for the last unit can't both succeed.
+	reservation, err := c.transactionRepo.Checkout(ctx.Request.Context(), &newTransaction, time.Now().Add(c.reservationTTL))
+	if err != nil {
+		if errors.Is(err, repositories.ErrInsufficientStock) {
+			c.metrics.CheckoutFailed(metrics.ReasonInsufficientStock)
+			apperror.Abort(ctx, apperror.New(http.StatusBadRequest, apperror.CodeInsufficientStock, "Insufficient product quantity"))
+		} else {
+			c.metrics.CheckoutFailed(metrics.ReasonInternalError)
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to create transaction"))
+		}
 		return
 	}
+	c.metrics.TransactionCreated()
 
-	productToUpdate.Quantity -= req.Quantity
+	util.MarkWrite(ctx, c.readYourWritesWindow)
 
-	if err := c.productRepo.UpdateProduct(&productToUpdate); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product quantity"})
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Transaction created successfully", "transaction": newTransaction, "reservation": reservation})
+}
+
+// PayTransaction confirms payment of a pending checkout before its
+// reservation runs out, completing the sale.
+func (c *TransactionController) PayTransaction(ctx *gin.Context) {
+	transactionId, ok := transactionID(ctx)
+	if !ok {
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{"message": "Transaction created successfully", "transaction": newTransaction})
-}
-
-func (c *TransactionController) GetTransactionByID(ctx *gin.Context) {
-	id := ctx.Param("id")
-	transactionId, err := strconv.ParseInt(id, 10, 64)
+	err := c.transactionRepo.CompletePayment(ctx.Request.Context(), ctx.GetInt64("user_id"), transactionId)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		c.metrics.CheckoutFailed(paymentFailureReason(err))
+		abortTransactionUpdate(ctx, err, "Failed to complete payment")
 		return
 	}
-	transaction, err := c.transactionRepo.GetTransactionByID(transactionId)
+	util.MarkWrite(ctx, c.readYourWritesWindow)
+
+	transaction, err := c.transactionRepo.UsePrimary().GetTransactionByID(ctx.Request.Context(), transactionId)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transaction"})
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve transaction"))
 		return
 	}
-	ctx.JSON(http.StatusOK, transaction)
+	c.metrics.PaymentCompleted(transaction.MerchantId, transaction.TotalPrice)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Payment completed successfully", "transaction": transaction})
 }
 
-func (c *TransactionController) ListTransactionsByMerchantID(ctx *gin.Context) {
-	var req models.TransactionRequest
-	merchantId := ctx.GetInt64("user_id")
-	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"invalid query parameters: ": err.Error()})
+// CancelTransaction abandons a pending checkout and releases its stock.
+func (c *TransactionController) CancelTransaction(ctx *gin.Context) {
+	transactionId, ok := transactionID(ctx)
+	if !ok {
 		return
 	}
 
-	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
+	err := c.transactionRepo.CancelTransaction(ctx.Request.Context(), ctx.GetInt64("user_id"), transactionId)
+	if err != nil {
+		abortTransactionUpdate(ctx, err, "Failed to cancel transaction")
+		return
+	}
+	util.MarkWrite(ctx, c.readYourWritesWindow)
 
-	transactions, totalRecords, err := c.transactionRepo.ListTransactionsByMerchantID(merchantId, req.Limit, req.Page)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Transaction cancelled successfully"})
+}
+
+// RefundTransaction refunds a completed sale of one of the merchant's
+// products and returns its stock.
+func (c *TransactionController) RefundTransaction(ctx *gin.Context) {
+	transactionId, ok := transactionID(ctx)
+	if !ok {
+		return
+	}
+
+	err := c.transactionRepo.RefundTransaction(ctx.Request.Context(), ctx.GetInt64("user_id"), transactionId)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions"})
+		abortTransactionUpdate(ctx, err, "Failed to refund transaction")
 		return
 	}
+	util.MarkWrite(ctx, c.readYourWritesWindow)
 
-	totalPages := util.CalculateTotalPages(totalRecords, req.Limit)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Transaction refunded successfully"})
+}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"transactions":  transactions,
-		"total_records": totalRecords,
-		"current_page":  req.Page,
-		"page_size":     req.Limit,
-		"total_pages":   totalPages,
-	})
+// transactionTotal prices quantity units at price, adding the delivery fee to
+// cheap items and discounting expensive ones.
+func transactionTotal(price float64, quantity int64) float64 {
+	var totalPrice int64
+	deliveryFee := 5000
+
+	if price < 15000 {
+		totalPrice = quantity*int64(price) + int64(deliveryFee)
+	} else if price > 50000 {
+		totalPrice = quantity * int64(price-(price*10/100))
+	}
+	return float64(totalPrice)
+}
+
+func transactionID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid transaction ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// paymentFailureReason classifies a CompletePayment error the way
+// abortTransactionUpdate reports it.
+func paymentFailureReason(err error) string {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return metrics.ReasonTransactionNotFound
+	case errors.Is(err, repositories.ErrReservationExpired):
+		return metrics.ReasonReservationExpired
+	case errors.Is(err, repositories.ErrTransactionStatus):
+		return metrics.ReasonTransactionStatus
+	default:
+		return metrics.ReasonInternalError
+	}
+}
+
+func abortTransactionUpdate(ctx *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeTransactionNotFound, "Transaction not found"))
+	case errors.Is(err, repositories.ErrReservationExpired):
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeReservationExpired, "The reservation for this transaction has expired"))
+	case errors.Is(err, repositories.ErrTransactionStatus):
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeTransactionStatus, "The transaction can't change from its current status"))
+	default:
+		apperror.Abort(ctx, apperror.Internal(err, message))
+	}
+}
+
+func (c *TransactionController) GetTransactionByID(ctx *gin.Context) {
+	id := ctx.Param("id")
+	transactionId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid transaction ID"))
+		return
+	}
+	transaction, err := c.transactionReader(ctx).GetTransactionByID(ctx.Request.Context(), transactionId)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeTransactionNotFound, "Transaction not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve transaction"))
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, transaction)
+}
+
+func (c *TransactionController) ListTransactionsByMerchantID(ctx *gin.Context) {
+	c.listTransactions(ctx, merchantTransactionsScope, c.transactionReader(ctx).ListTransactionsByMerchantID)
 }
 
 func (c *TransactionController) ListTransactionsByCustomerID(ctx *gin.Context) {
-	var req models.TransactionRequest
-	customerId := ctx.GetInt64("user_id")
+	c.listTransactions(ctx, customerTransactionsScope, c.transactionReader(ctx).ListTransactionsByCustomerID)
+}
+
+func (c *TransactionController) listTransactions(ctx *gin.Context, scope string,
+	list func(ctx context.Context, userId int64, filter models.TransactionQuery) (*models.Page[models.TransactionResponse], error)) {
+	var req models.TransactionQuery
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"invalid query parameters: ": err.Error()})
+		apperror.Abort(ctx, apperror.Validation(err))
 		return
 	}
 
 	req.Page, req.Limit = util.SetPaginationDefaults(req.Page, req.Limit)
 
-	transactions, totalRecords, err := c.transactionRepo.ListTransactionsByCustomerID(customerId, req.Limit, req.Page)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions"})
+	scope += ":" + req.Sort
+	cursor, ok := decodeCursor(ctx, c.cursors, scope, req.Cursor)
+	if !ok {
 		return
 	}
+	if cursor != nil {
+		req.Page, req.Position = 0, cursor
+	}
 
-	totalPages := util.CalculateTotalPages(totalRecords, req.Limit)
+	page, err := list(ctx.Request.Context(), ctx.GetInt64("user_id"), req)
+	if err != nil {
+		if errors.Is(err, repositories.ErrInvalidCursor) {
+			abortInvalidCursor(ctx, err)
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve transactions"))
+		}
+		return
+	}
+	links, err := pageLinks(ctx, c.cursors, scope, page)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve transactions"))
+		return
+	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"transactions":  transactions,
-		"total_records": totalRecords,
-		"current_page":  req.Page,
-		"page_size":     req.Limit,
-		"total_pages":   totalPages,
+	transactions := page.Items
+	if transactions == nil {
+		transactions = []models.TransactionResponse{}
+	}
+	ctx.JSON(http.StatusOK, models.PaginatedTransactionResponse{
+		Transactions: transactions,
+		TotalRecords: page.Total,
+		CurrentPage:  req.Page,
+		PageSize:     req.Limit,
+		TotalPages:   util.CalculateTotalPages(page.Total, req.Limit),
+		Links:        links,
 	})
 }
@@ -1,10 +1,11 @@
 package controllers
 
 import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/metrics"
 	"backend-hanssen-hilman/models"
 	"backend-hanssen-hilman/repositories"
 	"net/http"
-	"os"
 
 	"time"
 
@@ -15,30 +16,36 @@ import (
 )
 
 type UserController struct {
-	userRepo repositories.UserRepository
+	userRepo  repositories.UserRepository
+	jwtSecret string
+	metrics   *metrics.Metrics
 }
 
-func NewUserController(userRepo repositories.UserRepository) *UserController {
+func NewUserController(userRepo repositories.UserRepository, jwtSecret string, metrics *metrics.Metrics) *UserController {
 	return &UserController{
-		userRepo: userRepo,
+		userRepo:  userRepo,
+		jwtSecret: jwtSecret,
+		metrics:   metrics,
 	}
 }
 
 func (c *UserController) Login(ctx *gin.Context) {
 	var req models.LoginRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		apperror.Abort(ctx, apperror.Validation(err))
 		return
 	}
 
-	user, err := c.userRepo.GetUserByEmail(req.Email)
+	user, err := c.userRepo.GetUserByEmail(ctx.Request.Context(), req.Email)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, models.ErrorResponse{Message: "Invalid credentials"})
+		c.metrics.Login(false)
+		apperror.Abort(ctx, apperror.Unauthorized(apperror.CodeInvalidCredentials, "Invalid credentials"))
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		ctx.JSON(http.StatusUnauthorized, models.ErrorResponse{Message: "Invalid credentials"})
+		c.metrics.Login(false)
+		apperror.Abort(ctx, apperror.Unauthorized(apperror.CodeInvalidCredentials, "Invalid credentials"))
 		return
 	}
 
@@ -49,32 +56,37 @@ func (c *UserController) Login(ctx *gin.Context) {
 		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
 	})
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	tokenString, err := token.SignedString([]byte(c.jwtSecret))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Failed to generate token"})
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to generate token"))
 		return
 	}
 
+	c.metrics.Login(true)
 	ctx.JSON(http.StatusOK, models.LoginResponse{Token: tokenString})
 }
 
 func (c *UserController) Register(ctx *gin.Context) {
 	var req models.RegisterRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		apperror.Abort(ctx, apperror.Validation(err))
 		return
 	}
 
 	// Check if user already exists
-	_, err := c.userRepo.GetUserByEmail(req.Email)
+	_, err := c.userRepo.UsePrimary().GetUserByEmail(ctx.Request.Context(), req.Email)
+	if err == nil {
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeEmailTaken, "User with this email already exists"))
+		return
+	}
 	if err != gorm.ErrRecordNotFound {
-		ctx.JSON(http.StatusConflict, models.ErrorResponse{Message: "User with this email already exists"})
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to check existing users"))
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Failed to hash password"})
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to hash password"))
 		return
 	}
 
@@ -82,11 +94,6 @@ func (c *UserController) Register(ctx *gin.Context) {
 		req.Role = "customer"
 	}
 
-	if req.Role != "merchant" && req.Role != "customer" {
-		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Invalid role specified"})
-		return
-	}
-
 	newUser := models.User{
 		UserId:   "user_" + time.Now().Format("20060102150405"), // Simple unique ID generation
 		Name:     req.Name,
@@ -96,8 +103,8 @@ func (c *UserController) Register(ctx *gin.Context) {
 		Status:   "active",
 	}
 
-	if err := c.userRepo.CreateUser(&newUser); err != nil {
-		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Failed to register user"})
+	if err := c.userRepo.CreateUser(ctx.Request.Context(), &newUser); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to register user"))
 		return
 	}
 
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/util"
+	"errors"
+	"maps"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type VariantController struct {
+	productRepo repositories.ProductRepository
+	variantRepo repositories.VariantRepository
+}
+
+func NewVariantController(productRepo repositories.ProductRepository, variantRepo repositories.VariantRepository) *VariantController {
+	return &VariantController{productRepo: productRepo, variantRepo: variantRepo}
+}
+
+func (c *VariantController) CreateVariant(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return
+	}
+	var req models.VariantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return
+	}
+
+	variant := models.ProductVariant{ProductId: product.Id}
+	if !c.applyRequest(ctx, product, &variant, req) {
+		return
+	}
+
+	if err := c.variantRepo.CreateVariant(ctx.Request.Context(), &variant); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to create variant"))
+		return
+	}
+
+	ctx.Header("ETag", util.ETag("variant", variant.Id, variant.Version))
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Variant created successfully", "variant": variant})
+}
+
+// UpdateVariant replaces every field of the variant (PUT).
+func (c *VariantController) UpdateVariant(ctx *gin.Context) {
+	var req models.VariantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	product, variant, ok := c.loadOwnedVariant(ctx)
+	if !ok || !checkVariantIfMatch(ctx, variant) {
+		return
+	}
+	if !c.applyRequest(ctx, product, variant, req) {
+		return
+	}
+
+	if err := c.variantRepo.UpdateVariant(ctx.Request.Context(), variant); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			abortVariantPreconditionFailed(ctx)
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to update variant"))
+		}
+		return
+	}
+
+	ctx.Header("ETag", util.ETag("variant", variant.Id, variant.Version))
+	ctx.JSON(http.StatusOK, gin.H{"message": "Variant updated successfully", "variant": variant})
+}
+
+func (c *VariantController) DeleteVariant(ctx *gin.Context) {
+	_, variant, ok := c.loadOwnedVariant(ctx)
+	if !ok || !checkVariantIfMatch(ctx, variant) {
+		return
+	}
+
+	if err := c.variantRepo.DeleteVariant(ctx.Request.Context(), variant); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			abortVariantPreconditionFailed(ctx)
+		} else if errors.Is(err, repositories.ErrVariantReserved) {
+			apperror.Abort(ctx, apperror.Conflict(apperror.CodeVariantReserved, "The variant has stock reserved by pending checkouts"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to delete variant"))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Variant deleted successfully"})
+}
+
+// loadOwnedVariant resolves the :id and :variantId parameters to a variant
+// of one of the calling merchant's products.
+func (c *VariantController) loadOwnedVariant(ctx *gin.Context) (*models.Product, *models.ProductVariant, bool) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid product ID"))
+		return nil, nil, false
+	}
+	variantId, err := strconv.ParseInt(ctx.Param("variantId"), 10, 64)
+	if err != nil {
+		apperror.Abort(ctx, apperror.BadRequest("Invalid variant ID"))
+		return nil, nil, false
+	}
+
+	product, ok := loadOwnedProduct(ctx, c.productRepo, productId)
+	if !ok {
+		return nil, nil, false
+	}
+
+	variant, err := c.variantRepo.UsePrimary().GetVariant(ctx.Request.Context(), product.Id, variantId)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeVariantNotFound, "Variant not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve variant"))
+		}
+		return nil, nil, false
+	}
+	return product, variant, true
+}
+
+// applyRequest copies req onto variant after checking that its options match
+// the product's option axes, that no sibling has the same combination, and
+// that the SKU is unique across the merchant's catalog.
+func (c *VariantController) applyRequest(ctx *gin.Context, product *models.Product, variant *models.ProductVariant, req models.VariantRequest) bool {
+	var details []apperror.FieldError
+	if len(product.Options) == 0 {
+		details = append(details, apperror.FieldError{Field: "options", Rule: "options", Message: "the product has no options; set them on the product first"})
+	} else if len(req.Options) != len(product.Options) {
+		details = append(details, apperror.FieldError{Field: "options", Rule: "options", Message: "must set exactly the product's options"})
+	} else {
+		for _, axis := range product.Options {
+			if _, ok := req.Options[axis]; !ok {
+				details = append(details, apperror.FieldError{Field: "options." + axis, Rule: "required", Message: "is required"})
+			}
+		}
+	}
+	for _, sibling := range product.Variants {
+		if sibling.Id != variant.Id && maps.Equal(sibling.Options, req.Options) {
+			details = append(details, apperror.FieldError{Field: "options", Rule: "unique", Message: "another variant already has these options"})
+		}
+	}
+	if len(details) > 0 {
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = details
+		apperror.Abort(ctx, appErr)
+		return false
+	}
+
+	taken, err := c.variantRepo.UsePrimary().SkuTaken(ctx.Request.Context(), product.MerchantId, req.Sku, variant.Id)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to check SKU"))
+		return false
+	}
+	if taken {
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeSkuTaken, "Another variant already uses this SKU"))
+		return false
+	}
+
+	variant.Sku = req.Sku
+	variant.Options = req.Options
+	variant.Price = req.Price
+	variant.Quantity = *req.Quantity
+	return true
+}
+
+func checkVariantIfMatch(ctx *gin.Context, variant *models.ProductVariant) bool {
+	match := ctx.GetHeader("If-Match")
+	if match == "" {
+		apperror.Abort(ctx, apperror.New(http.StatusPreconditionRequired, apperror.CodePreconditionNeeded, "If-Match header is required"))
+		return false
+	}
+	if !util.ETagMatchesStrong(match, util.ETag("variant", variant.Id, variant.Version)) {
+		abortVariantPreconditionFailed(ctx)
+		return false
+	}
+	return true
+}
+
+func abortVariantPreconditionFailed(ctx *gin.Context) {
+	apperror.Abort(ctx, apperror.New(http.StatusPreconditionFailed, apperror.CodePreconditionFailed, "Variant was modified by another request"))
+}
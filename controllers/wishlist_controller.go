@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/metrics"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/util"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WishlistController struct {
+	wishlistRepo         repositories.WishlistRepository
+	productRepo          repositories.ProductRepository
+	transactionRepo      repositories.TransactionRepository
+	reservationTTL       time.Duration
+	readYourWritesWindow time.Duration
+	metrics              *metrics.Metrics
+}
+
+func NewWishlistController(wishlistRepo repositories.WishlistRepository, productRepo repositories.ProductRepository, transactionRepo repositories.TransactionRepository, reservationTTL, readYourWritesWindow time.Duration, metrics *metrics.Metrics) *WishlistController {
+	return &WishlistController{
+		wishlistRepo:         wishlistRepo,
+		productRepo:          productRepo,
+		transactionRepo:      transactionRepo,
+		reservationTTL:       reservationTTL,
+		readYourWritesWindow: readYourWritesWindow,
+		metrics:              metrics,
+	}
+}
+
+func (c *WishlistController) ListWishlists(ctx *gin.Context) {
+	wishlists, err := c.wishlistRepo.ListWishlists(ctx.Request.Context(), ctx.GetInt64("user_id"))
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to list wishlists"))
+		return
+	}
+	if wishlists == nil {
+		wishlists = []models.Wishlist{}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"wishlists": wishlists})
+}
+
+func (c *WishlistController) CreateWishlist(ctx *gin.Context) {
+	var req models.WishlistRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	wishlist := models.Wishlist{
+		CustomerId: ctx.GetInt64("user_id"),
+		Name:       req.Name,
+		Public:     req.Public,
+	}
+	if err := c.wishlistRepo.CreateWishlist(ctx.Request.Context(), &wishlist); err != nil {
+		abortWishlistError(ctx, err, "Failed to create wishlist")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Wishlist created successfully", "wishlist": wishlist})
+}
+
+// GetWishlist shows one of the customer's own wishlists, or another
+// customer's public one, with the current price of every item.
+func (c *WishlistController) GetWishlist(ctx *gin.Context) {
+	wishlist, ok := c.loadWishlist(ctx, false)
+	if !ok {
+		return
+	}
+
+	items, err := c.wishlistRepo.ListItems(ctx.Request.Context(), wishlist.Id)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve wishlist"))
+		return
+	}
+	products, err := c.productsOf(ctx, items)
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve wishlist"))
+		return
+	}
+
+	response := models.WishlistResponse{Wishlist: *wishlist, Items: []models.WishlistItemResponse{}}
+	for _, item := range items {
+		product, ok := products[item.ProductId]
+		if !ok {
+			continue
+		}
+		price, available, ok := wishlistUnit(product, item.VariantId)
+		if !ok {
+			continue
+		}
+
+		itemRes := models.WishlistItemResponse{
+			WishlistItem: item,
+			ProductName:  product.Name,
+			Sku:          product.Sku,
+			CurrentPrice: price,
+			InStock:      available >= item.Quantity,
+		}
+		if price < item.PriceAtAdd {
+			itemRes.PriceDrop = item.PriceAtAdd - price
+			itemRes.PriceDropped = true
+		}
+		response.Items = append(response.Items, itemRes)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *WishlistController) UpdateWishlist(ctx *gin.Context) {
+	id, ok := idParam(ctx, "id", "Invalid wishlist ID")
+	if !ok {
+		return
+	}
+	var req models.WishlistRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	wishlist := models.Wishlist{
+		Id:         id,
+		CustomerId: ctx.GetInt64("user_id"),
+		Name:       req.Name,
+		Public:     req.Public,
+	}
+	if err := c.wishlistRepo.UpdateWishlist(ctx.Request.Context(), &wishlist); err != nil {
+		abortWishlistError(ctx, err, "Failed to update wishlist")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Wishlist updated successfully"})
+}
+
+func (c *WishlistController) DeleteWishlist(ctx *gin.Context) {
+	id, ok := idParam(ctx, "id", "Invalid wishlist ID")
+	if !ok {
+		return
+	}
+
+	if err := c.wishlistRepo.DeleteWishlist(ctx.Request.Context(), ctx.GetInt64("user_id"), id); err != nil {
+		abortWishlistError(ctx, err, "Failed to delete wishlist")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Wishlist deleted successfully"})
+}
+
+func (c *WishlistController) AddItem(ctx *gin.Context) {
+	var req models.WishlistItemRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	wishlist, ok := c.loadWishlist(ctx, true)
+	if !ok {
+		return
+	}
+
+	product, err := c.productRepo.UsePrimary().GetProductByID(ctx.Request.Context(), req.ProductId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Product not found"))
+		} else {
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve product"))
+		}
+		return
+	}
+	if req.VariantId == 0 && len(product.Variants) > 0 {
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = []apperror.FieldError{{Field: "variant_id", Rule: "required", Message: "is required for products with variants"}}
+		apperror.Abort(ctx, appErr)
+		return
+	}
+	price, _, ok := wishlistUnit(&product.Product, req.VariantId)
+	if !ok {
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeVariantNotFound, "Variant not found"))
+		return
+	}
+
+	item := models.WishlistItem{
+		WishlistId: wishlist.Id,
+		ProductId:  product.Id,
+		VariantId:  req.VariantId,
+		Quantity:   req.Quantity,
+		PriceAtAdd: price,
+	}
+	if err := c.wishlistRepo.SaveItem(ctx.Request.Context(), &item); err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to save wishlist item"))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Item saved to wishlist"})
+}
+
+func (c *WishlistController) RemoveItem(ctx *gin.Context) {
+	itemId, ok := idParam(ctx, "itemId", "Invalid item ID")
+	if !ok {
+		return
+	}
+	wishlist, ok := c.loadWishlist(ctx, true)
+	if !ok {
+		return
+	}
+
+	removed, err := c.wishlistRepo.RemoveItems(ctx.Request.Context(), wishlist.Id, []int64{itemId})
+	if err != nil {
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to remove wishlist item"))
+		return
+	}
+	if removed == 0 {
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeWishlistItemNotFound, "Wishlist item not found"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Item removed from wishlist"})
+}
+
+// PurchaseItems checks out wishlist items in one go, reserving stock for
+// each as a pending transaction, and takes them off the list. If any item
+// can't be bought, nothing is.
+func (c *WishlistController) PurchaseItems(ctx *gin.Context) {
+	var req models.WishlistPurchaseRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.metrics.CheckoutFailed(metrics.ReasonInvalidRequest)
+		apperror.Abort(ctx, apperror.Validation(err))
+		return
+	}
+
+	wishlist, ok := c.loadWishlist(ctx, true)
+	if !ok {
+		return
+	}
+
+	items, err := c.wishlistRepo.UsePrimary().ListItems(ctx.Request.Context(), wishlist.Id)
+	if err != nil {
+		c.metrics.CheckoutFailed(metrics.ReasonInternalError)
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve wishlist"))
+		return
+	}
+	if len(req.ItemIds) > 0 {
+		items, ok = selectWishlistItems(ctx, items, req.ItemIds)
+		if !ok {
+			c.metrics.CheckoutFailed(metrics.ReasonInvalidRequest)
+			return
+		}
+	}
+	if len(items) == 0 {
+		c.metrics.CheckoutFailed(metrics.ReasonInvalidRequest)
+		appErr := apperror.BadRequest("The request is invalid")
+		appErr.Details = []apperror.FieldError{{Field: "item_ids", Rule: "required", Message: "the wishlist has no items to purchase"}}
+		apperror.Abort(ctx, appErr)
+		return
+	}
+
+	// Stock and prices come from the primary, as at checkout.
+	products, err := c.productsOf(ctx, items)
+	if err != nil {
+		c.metrics.CheckoutFailed(metrics.ReasonInternalError)
+		apperror.Abort(ctx, apperror.Internal(err, "Failed to retrieve products"))
+		return
+	}
+
+	transactions := make([]*models.Transaction, 0, len(items))
+	itemIds := make([]int64, 0, len(items))
+	for _, item := range items {
+		product, ok := products[item.ProductId]
+		if !ok {
+			c.metrics.CheckoutFailed(metrics.ReasonProductNotFound)
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeProductNotFound, "Product not found"))
+			return
+		}
+		if item.VariantId == 0 && len(product.Variants) > 0 {
+			c.metrics.CheckoutFailed(metrics.ReasonInvalidRequest)
+			appErr := apperror.BadRequest("The request is invalid")
+			appErr.Details = []apperror.FieldError{{Field: "item_ids", Rule: "variant", Message: "item " + strconv.FormatInt(item.Id, 10) + " needs a variant chosen"}}
+			apperror.Abort(ctx, appErr)
+			return
+		}
+		price, available, ok := wishlistUnit(product, item.VariantId)
+		if !ok {
+			c.metrics.CheckoutFailed(metrics.ReasonVariantNotFound)
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeVariantNotFound, "Variant not found"))
+			return
+		}
+		if available < item.Quantity {
+			c.metrics.CheckoutFailed(metrics.ReasonInsufficientStock)
+			apperror.Abort(ctx, apperror.New(http.StatusBadRequest, apperror.CodeInsufficientStock, "Insufficient product quantity"))
+			return
+		}
+
+		transaction := &models.Transaction{
+			ProductId:  item.ProductId,
+			Quantity:   item.Quantity,
+			TotalPrice: transactionTotal(price, item.Quantity),
+			CustomerId: wishlist.CustomerId,
+		}
+		if item.VariantId != 0 {
+			variantId := item.VariantId
+			transaction.VariantId = &variantId
+			for _, variant := range product.Variants {
+				if variant.Id == variantId {
+					transaction.Sku = variant.Sku
+				}
+			}
+		}
+		transactions = append(transactions, transaction)
+		itemIds = append(itemIds, item.Id)
+	}
+
+	reservations, err := c.transactionRepo.CheckoutAll(ctx.Request.Context(), transactions, time.Now().Add(c.reservationTTL))
+	if err != nil {
+		if errors.Is(err, repositories.ErrInsufficientStock) {
+			c.metrics.CheckoutFailed(metrics.ReasonInsufficientStock)
+			apperror.Abort(ctx, apperror.New(http.StatusBadRequest, apperror.CodeInsufficientStock, "Insufficient product quantity"))
+		} else {
+			c.metrics.CheckoutFailed(metrics.ReasonInternalError)
+			apperror.Abort(ctx, apperror.Internal(err, "Failed to create transactions"))
+		}
+		return
+	}
+	for range transactions {
+		c.metrics.TransactionCreated()
+	}
+	util.MarkWrite(ctx, c.readYourWritesWindow)
+
+	// The checkout already succeeded; items left behind can be removed by hand.
+	if _, err := c.wishlistRepo.RemoveItems(ctx.Request.Context(), wishlist.Id, itemIds); err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Failed to remove purchased wishlist items", "wishlist_id", wishlist.Id, "error", err)
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Transactions created successfully", "transactions": transactions, "reservations": reservations})
+}
+
+// loadWishlist resolves the :id parameter. Other customers' wishlists are
+// only found when they are public and ownerOnly is false.
+func (c *WishlistController) loadWishlist(ctx *gin.Context, ownerOnly bool) (*models.Wishlist, bool) {
+	id, ok := idParam(ctx, "id", "Invalid wishlist ID")
+	if !ok {
+		return nil, false
+	}
+
+	wishlist, err := c.wishlistRepo.UsePrimary().GetWishlist(ctx.Request.Context(), id)
+	if err != nil {
+		abortWishlistError(ctx, err, "Failed to retrieve wishlist")
+		return nil, false
+	}
+
+	owned := wishlist.CustomerId == ctx.GetInt64("user_id")
+	if !owned && (ownerOnly || !wishlist.Public) {
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeWishlistNotFound, "Wishlist not found"))
+		return nil, false
+	}
+	return wishlist, true
+}
+
+// productsOf loads the products of items from the primary, by ID.
+func (c *WishlistController) productsOf(ctx *gin.Context, items []models.WishlistItem) (map[int64]*models.Product, error) {
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductId)
+	}
+	products, err := c.productRepo.UsePrimary().GetProductsByIDs(ctx.Request.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int64]*models.Product, len(products))
+	for i := range products {
+		byId[products[i].Id] = &products[i]
+	}
+	return byId, nil
+}
+
+// wishlistUnit returns the unit price and stock of the product, or of its
+// variant when variantId isn't 0. It reports false for a missing variant.
+func wishlistUnit(product *models.Product, variantId int64) (float64, int64, bool) {
+	if variantId == 0 {
+		return product.Price, product.Quantity, true
+	}
+	for _, variant := range product.Variants {
+		if variant.Id == variantId {
+			return variant.UnitPrice(product.Price), variant.Quantity, true
+		}
+	}
+	return 0, 0, false
+}
+
+// selectWishlistItems picks the items with the given IDs, in that order.
+func selectWishlistItems(ctx *gin.Context, items []models.WishlistItem, ids []int64) ([]models.WishlistItem, bool) {
+	byId := make(map[int64]models.WishlistItem, len(items))
+	for _, item := range items {
+		byId[item.Id] = item
+	}
+
+	selected := make([]models.WishlistItem, 0, len(ids))
+	for _, id := range ids {
+		item, ok := byId[id]
+		if !ok {
+			apperror.Abort(ctx, apperror.NotFound(apperror.CodeWishlistItemNotFound, "Wishlist item not found"))
+			return nil, false
+		}
+		selected = append(selected, item)
+	}
+	return selected, true
+}
+
+func abortWishlistError(ctx *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeWishlistNotFound, "Wishlist not found"))
+	case errors.Is(err, repositories.ErrWishlistNameTaken):
+		apperror.Abort(ctx, apperror.Conflict(apperror.CodeWishlistNameTaken, "You already have a wishlist with this name"))
+	default:
+		apperror.Abort(ctx, apperror.Internal(err, message))
+	}
+}
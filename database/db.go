@@ -1,21 +1,154 @@
 package database
 
 import (
-	"gorm.io/driver/mysql"
+	"backend-hanssen-hilman/util"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
 
-	_ "github.com/go-sql-driver/mysql"
-	"gorm.io/gorm"
+// Supported values for DBConfig.Driver.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
-func Database() error {
-	var err error
-	DB, err = gorm.Open(mysql.Open(DBUrl(BuildConfig())), &gorm.Config{
-		SkipDefaultTransaction: true, // Improves performance by avoiding auto-transactions.
-		PrepareStmt:            true, // Caches compiled statements for performance and helps prevent SQL injection.
-	})
-	if err != nil {
-		return err
+// DBConfig represents db configuration
+type DBConfig struct {
+	Driver   string
+	Host     string
+	Port     string
+	User     string
+	DBName   string
+	Password string
+	SSLMode  string
+
+	// Replicas lists read replicas as "host:port". They share the primary's
+	// driver, credentials and database name.
+	Replicas []string
+
+	// ReadYourWritesWindow is how long a client's reads stay on the primary
+	// after it wrote, covering typical replication lag.
+	ReadYourWritesWindow time.Duration
+
+	// Connection pool settings. Zero keeps the database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// Startup retry: the first retry waits ConnectBackoff, doubling on every
+	// attempt up to ConnectMaxBackoff.
+	ConnectRetries    int
+	ConnectBackoff    time.Duration
+	ConnectMaxBackoff time.Duration
+
+	// StatsInterval controls how often pool statistics are logged. Zero
+	// disables the reporter.
+	StatsInterval time.Duration
+}
+
+// BuildDBConfig to set value of DBConfig
+func BuildConfig() *DBConfig {
+	dbConfig := DBConfig{
+		Driver:   os.Getenv("DB_DRIVER"),
+		Host:     os.Getenv("DB_HOST"),
+		Port:     os.Getenv("DB_PORT"),
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		DBName:   os.Getenv("DB_NAME"),
+		SSLMode:  os.Getenv("DB_SSLMODE"),
+		Replicas: splitList(os.Getenv("DB_REPLICAS")),
+
+		ReadYourWritesWindow: util.GetEnvDuration("DB_READ_YOUR_WRITES_WINDOW", 5*time.Second),
+
+		MaxOpenConns:    util.GetEnvInt("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    util.GetEnvInt("DB_MAX_IDLE_CONNS", 10),
+		ConnMaxLifetime: util.GetEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		ConnMaxIdleTime: util.GetEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+
+		ConnectRetries:    util.GetEnvInt("DB_CONNECT_RETRIES", 5),
+		ConnectBackoff:    util.GetEnvDuration("DB_CONNECT_BACKOFF", time.Second),
+		ConnectMaxBackoff: util.GetEnvDuration("DB_CONNECT_MAX_BACKOFF", 30*time.Second),
+
+		StatsInterval: util.GetEnvDuration("DB_STATS_INTERVAL", time.Minute),
+	}
+	if dbConfig.Driver == "" {
+		dbConfig.Driver = DriverMySQL
+	}
+	if dbConfig.SSLMode == "" {
+		dbConfig.SSLMode = "disable"
+	}
+	if dbConfig.Driver == DriverSQLite {
+		// SQLite serialises writers; a single connection also keeps an
+		// in-memory database alive for the lifetime of the pool, as long as
+		// the pool never closes it for being idle or old.
+		dbConfig.MaxOpenConns = util.GetEnvInt("DB_MAX_OPEN_CONNS", 1)
+		dbConfig.MaxIdleConns = max(dbConfig.MaxIdleConns, 1)
+		dbConfig.ConnMaxLifetime = 0
+		dbConfig.ConnMaxIdleTime = 0
+	}
+	return &dbConfig
+}
+
+// ReplicaConfigs derives one DBConfig per configured read replica.
+func (dbConfig *DBConfig) ReplicaConfigs() ([]*DBConfig, error) {
+	replicas := make([]*DBConfig, 0, len(dbConfig.Replicas))
+	for _, address := range dbConfig.Replicas {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replica address %q: %w", address, err)
+		}
+
+		replica := *dbConfig
+		replica.Host = host
+		replica.Port = port
+		replica.Replicas = nil
+		replicas = append(replicas, &replica)
+	}
+	return replicas, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
+
+// DbURL to generate connection string
+func DBUrl(dbConfig *DBConfig) string {
+	return fmt.Sprintf(
+		"%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local",
+		dbConfig.User,
+		dbConfig.Password,
+		dbConfig.Host,
+		dbConfig.Port,
+		dbConfig.DBName,
+	)
+}
+
+// PostgresURL generates a PostgreSQL connection string.
+func PostgresURL(dbConfig *DBConfig) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(dbConfig.User, dbConfig.Password),
+		Host:     net.JoinHostPort(dbConfig.Host, dbConfig.Port),
+		Path:     "/" + dbConfig.DBName,
+		RawQuery: url.Values{"sslmode": {dbConfig.SSLMode}, "TimeZone": {"UTC"}}.Encode(),
+	}
+	return dsn.String()
+}
 
-	return nil
+// SQLiteURL generates a SQLite connection string. DBName is the database
+// file path, or ":memory:" for a throwaway in-memory database.
+func SQLiteURL(dbConfig *DBConfig) string {
+	return dbConfig.DBName + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
 }
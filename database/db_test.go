@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestBuildConfigKeepsSQLiteMemoryAlive(t *testing.T) {
+	t.Setenv("DB_DRIVER", DriverSQLite)
+	t.Setenv("DB_NAME", ":memory:")
+	t.Setenv("DB_MAX_IDLE_CONNS", "0")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "10ms")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "10ms")
+
+	cfg := BuildConfig()
+	if cfg.MaxIdleConns < 1 || cfg.ConnMaxLifetime != 0 || cfg.ConnMaxIdleTime != 0 {
+		t.Errorf("pool = %d idle, %s lifetime, %s idle time; want at least 1 idle connection that never expires",
+			cfg.MaxIdleConns, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime)
+	}
+
+	db, err := Open(cfg, logger.Discard)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.Exec("CREATE TABLE kept (id INTEGER)").Error; err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := db.Exec("SELECT * FROM kept").Error; err != nil {
+		t.Errorf("table gone after the connection sat idle: %v", err)
+	}
+}
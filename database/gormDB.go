@@ -1,44 +1,166 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"log/slog"
+	"time"
 
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+
+	_ "github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
-// DB is gorm variable
-var DB *gorm.DB
+// Dialector returns the GORM dialector for the configured driver.
+func Dialector(dbConfig *DBConfig) (gorm.Dialector, error) {
+	switch dbConfig.Driver {
+	case DriverMySQL:
+		return mysql.Open(DBUrl(dbConfig)), nil
+	case DriverPostgres:
+		return postgres.Open(PostgresURL(dbConfig)), nil
+	case DriverSQLite:
+		return sqlite.Open(SQLiteURL(dbConfig)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", dbConfig.Driver)
+	}
+}
+
+// Open connects to the database described by dbConfig, retrying with
+// exponential backoff while the server is not reachable yet.
+func Open(dbConfig *DBConfig, gormLogger logger.Interface) (*gorm.DB, error) {
+	dialector, err := Dialector(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := dbConfig.ConnectBackoff
+	var db *gorm.DB
+	for attempt := 0; ; attempt++ {
+		db, err = gorm.Open(dialector, &gorm.Config{
+			SkipDefaultTransaction: true, // Improves performance by avoiding auto-transactions.
+			PrepareStmt:            true, // Caches compiled statements for performance and helps prevent SQL injection.
+			Logger:                 gormLogger,
+			TranslateError:         true, // Reports unique violations as gorm.ErrDuplicatedKey on every driver.
+		})
+		if err == nil || attempt >= dbConfig.ConnectRetries {
+			break
+		}
+
+		slog.Warn("Database not ready, retrying",
+			"attempt", attempt+1, "retries", dbConfig.ConnectRetries, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if dbConfig.ConnectMaxBackoff > 0 && backoff > dbConfig.ConnectMaxBackoff {
+			backoff = dbConfig.ConnectMaxBackoff
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := configurePool(db, dbConfig); err != nil {
+		return nil, err
+	}
+
+	if err := registerReplicas(db, dbConfig); err != nil {
+		return nil, err
+	}
 
-// DBConfig represents db configuration
-type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	DBName   string
-	Password string
+	return db, nil
 }
 
-// BuildDBConfig to set value of DBConfig
-func BuildConfig() *DBConfig {
-	dbConfig := DBConfig{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     os.Getenv("DB_PORT"),
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		DBName:   os.Getenv("DB_NAME"),
+// registerReplicas routes queries to the read replicas while writes, and
+// anything wrapped with Primary, keep using the primary connection.
+func registerReplicas(db *gorm.DB, dbConfig *DBConfig) error {
+	replicaConfigs, err := dbConfig.ReplicaConfigs()
+	if err != nil || len(replicaConfigs) == 0 {
+		return err
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(replicaConfigs))
+	for _, replicaConfig := range replicaConfigs {
+		dialector, err := Dialector(replicaConfig)
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, dialector)
 	}
-	return &dbConfig
+
+	return db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxOpenConns(dbConfig.MaxOpenConns).
+		SetMaxIdleConns(dbConfig.MaxIdleConns).
+		SetConnMaxLifetime(dbConfig.ConnMaxLifetime).
+		SetConnMaxIdleTime(dbConfig.ConnMaxIdleTime))
 }
 
-// DbURL to generate connection string
-func DBUrl(dbConfig *DBConfig) string {
-	return fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local",
-		dbConfig.User,
-		dbConfig.Password,
-		dbConfig.Host,
-		dbConfig.Port,
-		dbConfig.DBName,
-	)
+// Primary returns a reusable handle whose reads go to the primary, for
+// read-your-writes and for reads that precede a write in the same flow.
+// Without replicas it behaves exactly like db.
+func Primary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+func configurePool(db *gorm.DB, dbConfig *DBConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(dbConfig.ConnMaxIdleTime)
+	return nil
+}
+
+// Ping checks that the database answers within timeout.
+func Ping(ctx context.Context, db *gorm.DB, timeout time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// ReportPoolStats logs connection pool statistics every interval until ctx is
+// cancelled.
+func ReportPoolStats(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := sqlDB.Stats()
+			slog.Info("DB pool stats",
+				"open", stats.OpenConnections,
+				"in_use", stats.InUse,
+				"idle", stats.Idle,
+				"wait_count", stats.WaitCount,
+				"wait_duration", stats.WaitDuration,
+				"max_idle_closed", stats.MaxIdleClosed,
+				"max_lifetime_closed", stats.MaxLifetimeClosed)
+		}
+	}
 }
@@ -0,0 +1,76 @@
+// Package imaging validates uploaded images and renders thumbnails in pure Go.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels bounds decoded images so a small file that claims huge
+// dimensions can't exhaust memory.
+const MaxPixels = 40_000_000
+
+// ContentTypes maps the accepted image types to their file extensions.
+var ContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// Decode reads an image after checking its dimensions against MaxPixels.
+func Decode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Thumbnail scales img down so that neither side exceeds size, keeping the
+// aspect ratio. Images that already fit are returned unchanged.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*size/width)
+		width = size
+	} else {
+		width = max(1, width*size/height)
+		height = size
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Src, nil)
+	return thumb
+}
+
+// Encode writes img as PNG when the source was PNG or GIF, which keeps
+// transparency, and as JPEG otherwise. It returns the content type written.
+func Encode(w io.Writer, img image.Image, sourceType string) (string, error) {
+	switch sourceType {
+	case "image/png", "image/gif":
+		return "image/png", png.Encode(w, img)
+	default:
+		return "image/jpeg", jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+}
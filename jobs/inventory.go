@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"backend-hanssen-hilman/repositories"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// releaseBatchSize bounds how many reservations one sweep releases, so a
+// backlog is worked off over several ticks.
+const releaseBatchSize = 500
+
+// ReleaseExpiredReservations cancels checkouts that weren't paid for in time
+// and returns their reserved stock, every interval until ctx is cancelled.
+func ReleaseExpiredReservations(ctx context.Context, repo repositories.TransactionRepository, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := repo.ReleaseExpiredReservations(ctx, time.Now(), releaseBatchSize)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to release expired reservations", "error", err)
+			}
+			if released > 0 {
+				slog.InfoContext(ctx, "Released expired reservations", "count", released)
+			}
+		}
+	}
+}
+
+// ReconcileInventory checks every stock count against the inventory ledger
+// right away, which also opens the ledger for stock from before it existed,
+// and then again every interval until ctx is cancelled. An interval of 0
+// only does the initial check.
+func ReconcileInventory(ctx context.Context, repo repositories.InventoryRepository, interval time.Duration) {
+	reconcile := func() {
+		opened, corrected, err := repo.ReconcileStock(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to reconcile inventory", "error", err)
+		}
+		if opened > 0 || corrected > 0 {
+			slog.InfoContext(ctx, "Reconciled inventory", "opened", opened, "corrected", corrected)
+		}
+	}
+
+	reconcile()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reconcile()
+		}
+	}
+}
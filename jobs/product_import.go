@@ -0,0 +1,218 @@
+package jobs
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/bulk"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/search"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// importProgressInterval is how many rows an import processes between
+// progress saves.
+const importProgressInterval = 200
+
+// ProductImporter applies import files to a merchant's catalog, creating
+// products with new SKUs and updating the ones whose SKU already exists.
+type ProductImporter struct {
+	productRepo  repositories.ProductRepository
+	categoryRepo repositories.CategoryRepository
+	importRepo   repositories.ImportJobRepository
+	index        search.SearchIndex
+}
+
+func NewProductImporter(productRepo repositories.ProductRepository, categoryRepo repositories.CategoryRepository, importRepo repositories.ImportJobRepository, index search.SearchIndex) *ProductImporter {
+	return &ProductImporter{
+		productRepo:  productRepo.UsePrimary(),
+		categoryRepo: categoryRepo.UsePrimary(),
+		importRepo:   importRepo.UsePrimary(),
+		index:        index,
+	}
+}
+
+// Run imports the file at path into job's merchant catalog, saving the
+// job's progress as it goes, and removes the file once done. Invalid rows
+// are recorded on the job and skipped; a file that can't be read any
+// further fails the job.
+func (im *ProductImporter) Run(ctx context.Context, job *models.ImportJob, path string) {
+	defer os.Remove(path)
+
+	job.Status = models.ImportStatusRunning
+	im.save(ctx, job)
+
+	err := im.importFile(ctx, job, path)
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = models.ImportStatusFailed
+		job.Message = err.Error()
+		slog.WarnContext(ctx, "Product import failed", "job_id", job.Id, "error", err)
+	} else {
+		job.Status = models.ImportStatusCompleted
+	}
+	im.save(ctx, job)
+}
+
+func (im *ProductImporter) importFile(ctx context.Context, job *models.ImportJob, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := bulk.NewReader(job.Format, file)
+	if err != nil {
+		return err
+	}
+
+	categories, err := im.categoryRepo.ListCategories(ctx)
+	if err != nil {
+		return err
+	}
+	bySlug := make(map[string]models.Category, len(categories))
+	for _, category := range categories {
+		bySlug[category.Slug] = category
+	}
+
+	// A dry run writes nothing, so it remembers the new SKUs itself to count
+	// a repeated one as an update.
+	seen := map[string]bool{}
+	for {
+		row, rowNum, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var rowErr *bulk.RowError
+		if errors.As(err, &rowErr) {
+			job.Processed++
+			im.fail(job, rowNum, row.Sku, []apperror.FieldError{{Field: rowErr.Field, Rule: rowErr.Rule, Message: rowErr.Message}})
+		} else if err != nil {
+			return err
+		} else {
+			job.Processed++
+			if err := im.importRow(ctx, job, rowNum, row, bySlug, seen); err != nil {
+				return err
+			}
+		}
+
+		if job.Processed%importProgressInterval == 0 {
+			im.save(ctx, job)
+		}
+	}
+}
+
+// importRow applies one row. Only errors that should stop the import are
+// returned; problems with the row itself are recorded on the job.
+func (im *ProductImporter) importRow(ctx context.Context, job *models.ImportJob, rowNum int64, row models.ProductImportRow, bySlug map[string]models.Category, seen map[string]bool) error {
+	if err := binding.Validator.ValidateStruct(&row); err != nil {
+		im.fail(job, rowNum, row.Sku, apperror.Validation(err).Details)
+		return nil
+	}
+
+	var categories []models.Category
+	if row.Categories != nil {
+		for _, slug := range *row.Categories {
+			category, ok := bySlug[slug]
+			if !ok {
+				im.fail(job, rowNum, row.Sku, []apperror.FieldError{{Field: "categories", Rule: "exists", Message: "contains an unknown category"}})
+				return nil
+			}
+			if !slices.ContainsFunc(categories, func(c models.Category) bool { return c.Id == category.Id }) {
+				categories = append(categories, category)
+			}
+		}
+	}
+
+	product, err := im.productRepo.GetProductBySku(ctx, job.MerchantId, row.Sku)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if product != nil && product.DeletedAt.Valid {
+		im.fail(job, rowNum, row.Sku, []apperror.FieldError{{Field: "sku", Rule: "deleted", Message: "belongs to a deleted product; restore it first"}})
+		return nil
+	}
+	if product != nil && len(product.Variants) > 0 && product.Quantity != *row.Quantity {
+		im.fail(job, rowNum, row.Sku, []apperror.FieldError{{Field: "quantity", Rule: "variants", Message: "is kept on the variants of this product"}})
+		return nil
+	}
+
+	if job.DryRun {
+		if product == nil && !seen[row.Sku] {
+			seen[row.Sku] = true
+			job.Created++
+		} else {
+			job.Updated++
+		}
+		return nil
+	}
+
+	if product == nil {
+		sku := row.Sku
+		product = &models.Product{
+			Sku:         &sku,
+			Name:        row.Name,
+			Description: row.Description,
+			Price:       *row.Price,
+			MerchantId:  job.MerchantId,
+			Quantity:    *row.Quantity,
+			Categories:  categories,
+		}
+		if err := im.productRepo.CreateProduct(ctx, product); err != nil {
+			return err
+		}
+		job.Created++
+	} else {
+		product.Name = row.Name
+		product.Description = row.Description
+		product.Price = *row.Price
+		product.Quantity = *row.Quantity
+		if row.Categories != nil {
+			product.Categories = categories
+		}
+		if err := im.productRepo.UpdateProduct(ctx, product); err != nil {
+			if errors.Is(err, repositories.ErrVersionConflict) {
+				im.fail(job, rowNum, row.Sku, []apperror.FieldError{{Rule: "conflict", Message: "The product was modified by another request"}})
+				return nil
+			}
+			return err
+		}
+		job.Updated++
+	}
+	im.index.Index(search.Document{ID: product.Id, Name: product.Name, Description: product.Description})
+	return nil
+}
+
+// fail records the errors of a skipped row, keeping at most
+// models.MaxImportErrors of them.
+func (im *ProductImporter) fail(job *models.ImportJob, rowNum int64, sku string, details []apperror.FieldError) {
+	job.Failed++
+	for _, detail := range details {
+		if len(job.Errors) >= models.MaxImportErrors {
+			job.ErrorsTruncated = true
+			return
+		}
+		job.Errors = append(job.Errors, models.ImportRowError{
+			Row:     rowNum,
+			Sku:     sku,
+			Field:   detail.Field,
+			Rule:    detail.Rule,
+			Message: detail.Message,
+		})
+	}
+}
+
+func (im *ProductImporter) save(ctx context.Context, job *models.ImportJob) {
+	if err := im.importRepo.SaveImportJob(ctx, job); err != nil {
+		slog.ErrorContext(ctx, "Failed to save import progress", "job_id", job.Id, "error", err)
+	}
+}
@@ -0,0 +1,43 @@
+// Package jobs holds the background work that runs next to the HTTP server.
+package jobs
+
+import (
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/storage"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PurgeDeletedProducts permanently removes products that were soft-deleted
+// more than retention ago, every interval until ctx is cancelled. Products
+// that still have transactions are kept so order history stays readable.
+// The purged products' image files are then removed from store.
+func PurgeDeletedProducts(ctx context.Context, repo repositories.ProductRepository, store storage.BlobStore, retention, interval time.Duration) {
+	if interval <= 0 || retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, blobKeys, err := repo.PurgeDeletedProducts(ctx, time.Now().Add(-retention))
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to purge deleted products", "error", err)
+				continue
+			}
+			for _, key := range blobKeys {
+				if err := store.Delete(ctx, key); err != nil {
+					slog.ErrorContext(ctx, "Failed to delete purged product image", "key", key, "error", err)
+				}
+			}
+			if purged > 0 {
+				slog.InfoContext(ctx, "Purged deleted products", "count", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/search"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const reindexBatchSize = 1000
+
+// ReindexProducts fills index from the database right away and then rebuilds
+// it every interval until ctx is cancelled, so it catches up with products
+// changed by other instances. An interval of 0 only does the initial build.
+func ReindexProducts(ctx context.Context, repo repositories.ProductRepository, index search.SearchIndex, interval time.Duration) {
+	rebuildIndex(ctx, repo, index)
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rebuildIndex(ctx, repo, index)
+		}
+	}
+}
+
+func rebuildIndex(ctx context.Context, repo repositories.ProductRepository, index search.SearchIndex) {
+	var docs []search.Document
+	var afterId int64
+	for {
+		products, err := repo.ListSearchable(ctx, afterId, reindexBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to rebuild search index", "error", err)
+			return
+		}
+		for _, product := range products {
+			docs = append(docs, search.Document{ID: product.Id, Name: product.Name, Description: product.Description})
+		}
+		if len(products) < reindexBatchSize {
+			break
+		}
+		afterId = products[len(products)-1].Id
+	}
+
+	index.Reset(docs)
+	slog.DebugContext(ctx, "Rebuilt search index", "documents", len(docs))
+}
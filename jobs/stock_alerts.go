@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/notify"
+	"backend-hanssen-hilman/repositories"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// stockAlertsCursor names the job's position in the inventory ledger.
+const stockAlertsCursor = "stock_alerts"
+
+const stockAlertBatchSize = 500
+
+// stockAlertSettle is how old a movement must be before it is checked. IDs
+// are taken when a movement is written but only show once its transaction
+// commits, so a lower ID can appear after a higher one; waiting out any
+// transaction still writing keeps the cursor from passing over it.
+const stockAlertSettle = time.Minute
+
+// StockAlerter watches the inventory ledger for stock crossing a
+// threshold. Merchants hear when stock runs low or out, and subscribed
+// customers when it comes back.
+type StockAlerter struct {
+	inventoryRepo    repositories.InventoryRepository
+	productRepo      repositories.ProductRepository
+	notificationRepo repositories.NotificationRepository
+	cursorRepo       repositories.JobCursorRepository
+	notifier         notify.Notifier
+	settle           time.Duration
+}
+
+func NewStockAlerter(inventoryRepo repositories.InventoryRepository, productRepo repositories.ProductRepository, notificationRepo repositories.NotificationRepository, cursorRepo repositories.JobCursorRepository, notifier notify.Notifier) *StockAlerter {
+	return &StockAlerter{
+		inventoryRepo:    inventoryRepo.UsePrimary(),
+		productRepo:      productRepo.UsePrimary(),
+		notificationRepo: notificationRepo.UsePrimary(),
+		cursorRepo:       cursorRepo.UsePrimary(),
+		notifier:         notifier,
+		settle:           stockAlertSettle,
+	}
+}
+
+// Run checks the movements recorded since the last check every interval
+// until ctx is cancelled. The first run starts from the end of the ledger.
+func (a *StockAlerter) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.check(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to check stock alerts", "error", err)
+			}
+		}
+	}
+}
+
+func (a *StockAlerter) check(ctx context.Context) error {
+	last, err := a.inventoryRepo.LastMovementId(ctx)
+	if err != nil {
+		return err
+	}
+	position, err := a.cursorRepo.GetJobCursor(ctx, stockAlertsCursor, last)
+	if err != nil {
+		return err
+	}
+
+	for {
+		movements, err := a.inventoryRepo.ListMovementsAfter(ctx, position, stockAlertBatchSize)
+		if err != nil {
+			return err
+		}
+		movements = settled(movements, time.Now().Add(-a.settle))
+		if len(movements) == 0 {
+			return nil
+		}
+
+		// Claiming the batch first means an alert is sent at most once,
+		// even with several instances running the job.
+		next := movements[len(movements)-1].Id
+		claimed, err := a.cursorRepo.AdvanceJobCursor(ctx, stockAlertsCursor, position, next)
+		if err != nil || !claimed {
+			return err
+		}
+		if err := a.alert(ctx, movements); err != nil {
+			return err
+		}
+
+		if len(movements) < stockAlertBatchSize {
+			return nil
+		}
+		position = next
+	}
+}
+
+// settled returns the leading movements written before cutoff. The rest
+// wait for the next check, along with any lower IDs still being committed.
+func settled(movements []models.InventoryMovement, cutoff time.Time) []models.InventoryMovement {
+	for i, movement := range movements {
+		if movement.CreatedAt.After(cutoff) {
+			return movements[:i]
+		}
+	}
+	return movements
+}
+
+func (a *StockAlerter) alert(ctx context.Context, movements []models.InventoryMovement) error {
+	ids := make([]int64, 0, len(movements))
+	for _, movement := range movements {
+		ids = append(ids, movement.ProductId)
+	}
+	products, err := a.productRepo.GetProductsByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byId := make(map[int64]*models.Product, len(products))
+	for i := range products {
+		byId[products[i].Id] = &products[i]
+	}
+
+	// A paid checkout releases its hold and records the sale, so only the
+	// sale counts; releases of other holds return stock for good.
+	var released []int64
+	for _, movement := range movements {
+		if movement.Reason == models.MovementRelease && movement.TransactionId != nil {
+			released = append(released, *movement.TransactionId)
+		}
+	}
+	sold, err := a.inventoryRepo.SoldTransactions(ctx, released)
+	if err != nil {
+		return err
+	}
+
+	for _, movement := range movements {
+		// Holds come and go with checkouts and aren't stock changes.
+		if movement.Reason == models.MovementReservation ||
+			(movement.Reason == models.MovementRelease && movement.TransactionId != nil && sold[*movement.TransactionId]) {
+			continue
+		}
+		// Deleted products don't alert anyone.
+		product, ok := byId[movement.ProductId]
+		if !ok {
+			continue
+		}
+		item := stockItem{product: product, variantId: movement.VariantId}
+		previous := movement.Balance - movement.Delta
+		threshold := product.LowStockThreshold
+
+		switch {
+		case previous > 0 && movement.Balance <= 0:
+			a.send(ctx, item.notification(product.MerchantId, models.NotificationOutOfStock, movement.Balance))
+		case threshold != nil && previous > *threshold && movement.Balance <= *threshold:
+			a.send(ctx, item.notification(product.MerchantId, models.NotificationLowStock, movement.Balance))
+		case previous <= 0 && movement.Balance > 0:
+			var variantId int64
+			if movement.VariantId != nil {
+				variantId = *movement.VariantId
+			}
+			customerIds, err := a.notificationRepo.ClaimSubscribers(ctx, product.Id, variantId)
+			if err != nil {
+				return err
+			}
+			for _, customerId := range customerIds {
+				a.send(ctx, item.notification(customerId, models.NotificationBackInStock, movement.Balance))
+			}
+		}
+	}
+	return nil
+}
+
+// send reports delivery failures without stopping the other alerts.
+func (a *StockAlerter) send(ctx context.Context, notification *models.Notification) {
+	if err := a.notifier.Notify(ctx, notification); err != nil {
+		slog.WarnContext(ctx, "Failed to deliver notification",
+			"user_id", notification.UserId, "kind", notification.Kind, "error", err)
+	}
+}
+
+// stockItem is the product, or one of its variants, whose stock moved.
+type stockItem struct {
+	product   *models.Product
+	variantId *int64
+}
+
+func (s stockItem) notification(userId int64, kind string, quantity int64) *models.Notification {
+	name := s.product.Name
+	data := map[string]any{"product_id": s.product.Id, "quantity": quantity}
+	if s.variantId != nil {
+		data["variant_id"] = *s.variantId
+		for _, variant := range s.product.Variants {
+			if variant.Id == *s.variantId {
+				name = fmt.Sprintf("%s (%s)", name, variant.Sku)
+			}
+		}
+	}
+
+	notification := &models.Notification{UserId: userId, ProductId: &s.product.Id, Kind: kind, Data: data}
+	switch kind {
+	case models.NotificationOutOfStock:
+		notification.Title = "Out of stock: " + name
+		notification.Body = name + " has sold out."
+	case models.NotificationLowStock:
+		data["threshold"] = *s.product.LowStockThreshold
+		notification.Title = "Low stock: " + name
+		notification.Body = fmt.Sprintf("%s is down to %d, at or below your threshold of %d.", name, quantity, *s.product.LowStockThreshold)
+	case models.NotificationBackInStock:
+		notification.Title = "Back in stock: " + name
+		notification.Body = name + " is available again."
+	}
+	return notification
+}
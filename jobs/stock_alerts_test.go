@@ -0,0 +1,187 @@
+package jobs
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/migrations"
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Open(&database.DBConfig{
+		Driver:       database.DriverSQLite,
+		DBName:       filepath.Join(t.TempDir(), "shop.db"),
+		MaxOpenConns: 1,
+		MaxIdleConns: 1,
+	}, logger.Discard)
+	if err != nil {
+		t.Fatalf("database.Open: %v", err)
+	}
+	migrations.Migrate(db)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// recordingNotifier keeps what it is asked to send.
+type recordingNotifier struct {
+	sent []*models.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification *models.Notification) error {
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+type alerterFixture struct {
+	db            *gorm.DB
+	alerter       *StockAlerter
+	notifier      *recordingNotifier
+	products      repositories.ProductRepository
+	transactions  repositories.TransactionRepository
+	notifications repositories.NotificationRepository
+}
+
+func newAlerterFixture(t *testing.T) *alerterFixture {
+	t.Helper()
+	db := newTestDB(t)
+	f := &alerterFixture{
+		db:            db,
+		notifier:      &recordingNotifier{},
+		products:      repositories.NewProductRepository(db),
+		transactions:  repositories.NewTransactionRepository(db),
+		notifications: repositories.NewNotificationRepository(db),
+	}
+	f.alerter = NewStockAlerter(repositories.NewInventoryRepository(db), f.products, f.notifications,
+		repositories.NewJobCursorRepository(db), f.notifier)
+	f.alerter.settle = 0
+	return f
+}
+
+// check runs the alerter and returns what it sent.
+func (f *alerterFixture) check(t *testing.T) []*models.Notification {
+	t.Helper()
+	f.notifier.sent = nil
+	if err := f.alerter.check(context.Background()); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	return f.notifier.sent
+}
+
+// soldOut creates a product with one unit, reserves it for customer 2 and
+// subscribes customer 3, who sees it as sold out.
+func (f *alerterFixture) soldOut(t *testing.T) (*models.Product, *models.Transaction) {
+	t.Helper()
+	ctx := context.Background()
+	product := &models.Product{Name: "Shirt", Price: 100, MerchantId: 1, Quantity: 1}
+	if err := f.products.CreateProduct(ctx, product); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	f.check(t)
+
+	transaction := &models.Transaction{ProductId: product.Id, Quantity: 1, TotalPrice: 100, CustomerId: 2}
+	if _, err := f.transactions.Checkout(ctx, transaction, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	if err := f.notifications.Subscribe(ctx, &models.StockSubscription{CustomerId: 3, ProductId: product.Id}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	return product, transaction
+}
+
+func kinds(notifications []*models.Notification) map[string][]int64 {
+	users := make(map[string][]int64)
+	for _, notification := range notifications {
+		users[notification.Kind] = append(users[notification.Kind], notification.UserId)
+	}
+	return users
+}
+
+func TestStockAlerterIgnoresHolds(t *testing.T) {
+	f := newAlerterFixture(t)
+	f.soldOut(t)
+
+	if sent := f.check(t); len(sent) != 0 {
+		t.Errorf("reserving the last unit sent %v, want nothing", kinds(sent))
+	}
+}
+
+func TestStockAlerterPaidCheckout(t *testing.T) {
+	f := newAlerterFixture(t)
+	_, transaction := f.soldOut(t)
+
+	if err := f.transactions.CompletePayment(context.Background(), 2, transaction.Id); err != nil {
+		t.Fatalf("CompletePayment: %v", err)
+	}
+
+	sent := kinds(f.check(t))
+	if len(sent) != 1 || len(sent[models.NotificationOutOfStock]) != 1 || sent[models.NotificationOutOfStock][0] != 1 {
+		t.Errorf("paying for the last unit sent %v, want one out-of-stock alert to the merchant", sent)
+	}
+
+	var armed int64
+	f.db.Model(&models.StockSubscription{}).Where("notified_at IS NULL").Count(&armed)
+	if armed != 1 {
+		t.Errorf("%d armed subscriptions after the sale, want 1", armed)
+	}
+}
+
+func TestStockAlerterExpiredHold(t *testing.T) {
+	f := newAlerterFixture(t)
+	f.soldOut(t)
+
+	if _, err := f.transactions.ReleaseExpiredReservations(context.Background(), time.Now().Add(time.Hour), 10); err != nil {
+		t.Fatalf("ReleaseExpiredReservations: %v", err)
+	}
+
+	sent := kinds(f.check(t))
+	if len(sent) != 1 || len(sent[models.NotificationBackInStock]) != 1 || sent[models.NotificationBackInStock][0] != 3 {
+		t.Errorf("releasing the hold sent %v, want one back-in-stock alert to the subscriber", sent)
+	}
+}
+
+func TestStockAlerterWaitsForLateCommits(t *testing.T) {
+	f := newAlerterFixture(t)
+	product := &models.Product{Name: "Shirt", Price: 100, MerchantId: 1, Quantity: 5}
+	if err := f.products.CreateProduct(context.Background(), product); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	f.check(t)
+	var last int64
+	f.db.Model(&models.InventoryMovement{}).Select("MAX(id)").Row().Scan(&last)
+
+	// The higher ID is visible first; the lower one commits later.
+	movement := func(id, delta, balance int64, age time.Duration) {
+		t.Helper()
+		err := f.db.Create(&models.InventoryMovement{
+			Id: id, ProductId: product.Id, Reason: models.MovementAdjustment,
+			Delta: delta, Balance: balance, CreatedAt: time.Now().Add(-age),
+		}).Error
+		if err != nil {
+			t.Fatalf("Create movement: %v", err)
+		}
+	}
+	f.alerter.settle = time.Minute
+	movement(last+2, 1, 1, 30*time.Second)
+	if sent := f.check(t); len(sent) != 0 {
+		t.Errorf("unsettled movement sent %v", kinds(sent))
+	}
+
+	movement(last+1, -5, 0, 40*time.Second)
+	f.alerter.settle = 20 * time.Second
+	sent := kinds(f.check(t))
+	if len(sent[models.NotificationOutOfStock]) != 1 {
+		t.Errorf("late movement sent %v, want an out-of-stock alert", sent)
+	}
+}
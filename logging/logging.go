@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"backend-hanssen-hilman/util"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Config represents logging configuration
+type Config struct {
+	Level slog.Level
+
+	// SlowQueryThreshold marks queries that take longer as warnings.
+	SlowQueryThreshold time.Duration
+	// LogQueries logs every SQL statement instead of only slow and failed ones.
+	LogQueries bool
+	// LogQueryParams interpolates bind values into logged SQL. Off by default
+	// because values include password hashes and other personal data.
+	LogQueryParams bool
+}
+
+// BuildConfig to set value of Config
+func BuildConfig() *Config {
+	logConfig := Config{
+		SlowQueryThreshold: util.GetEnvDuration("LOG_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+		LogQueries:         os.Getenv("LOG_QUERIES") == "true",
+		LogQueryParams:     os.Getenv("LOG_QUERY_PARAMS") == "true",
+	}
+	if err := logConfig.Level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		logConfig.Level = slog.LevelInfo
+	}
+	return &logConfig
+}
+
+// New builds a JSON logger that writes to stdout.
+func New(logConfig *Config) *slog.Logger {
+	return NewWithWriter(os.Stdout, logConfig)
+}
+
+// NewWithWriter builds a JSON logger that writes to w. Sensitive attributes
+// are redacted, and request metadata stored in the context with WithRequestID
+// and WithUser is attached to every record logged with a context.
+func NewWithWriter(w io.Writer, logConfig *Config) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       logConfig.Level,
+		ReplaceAttr: redact,
+	})
+	return slog.New(contextHandler{Handler: handler})
+}
+
+// NewGormLogger routes GORM's logging through logger.
+func NewGormLogger(logger *slog.Logger, logConfig *Config) gormlogger.Interface {
+	level := gormlogger.Warn
+	if logConfig.LogQueries {
+		level = gormlogger.Info
+	}
+
+	return gormlogger.NewSlogLogger(logger, gormlogger.Config{
+		LogLevel:                  level,
+		SlowThreshold:             logConfig.SlowQueryThreshold,
+		ParameterizedQueries:      !logConfig.LogQueryParams,
+		IgnoreRecordNotFoundError: true,
+	})
+}
+
+// sensitiveKeys are matched as substrings of lower-cased attribute keys.
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie"}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, "[REDACTED]")
+		}
+	}
+	return a
+}
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userKey
+)
+
+type user struct {
+	id   int64
+	role string
+}
+
+// WithRequestID stores the request ID in ctx.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithUser stores the authenticated user in ctx.
+func WithUser(ctx context.Context, userID int64, role string) context.Context {
+	return context.WithValue(ctx, userKey, user{id: userID, role: role})
+}
+
+// contextHandler adds request metadata carried by the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if u, ok := ctx.Value(userKey).(user); ok {
+		record.AddAttrs(slog.Int64("user_id", u.id), slog.String("role", u.role))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -1,29 +1,61 @@
 package main
 
 import (
+	"backend-hanssen-hilman/app"
+	"backend-hanssen-hilman/config"
 	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/jobs"
+	"backend-hanssen-hilman/logging"
 	"backend-hanssen-hilman/migrations"
-	"backend-hanssen-hilman/routes"
 	"backend-hanssen-hilman/util"
-	"fmt"
-
-	"log"
+	"context"
+	"log/slog"
+	"os"
 )
 
 func main() {
 	// Load environment variables
 	util.LoadEnv()
+	cfg := config.Load()
+
+	logger := logging.New(cfg.Log)
+	slog.SetDefault(logger)
 
 	// Initialize database connection
-	err := database.Database()
+	db, err := database.Open(cfg.Database, logging.NewGormLogger(logger, cfg.Log))
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logger.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
 	}
-	fmt.Println("Database connection successful.")
+	logger.Info("Database connection successful")
+	go database.ReportPoolStats(context.Background(), db, cfg.Database.StatsInterval)
 
 	// Run migrations
-	migrations.Migrate(database.DB)
+	migrations.Migrate(db)
 
 	// Setup and run the router
-	routes.SetupRoutes()
+	application, err := app.New(cfg, db, logger)
+	if err != nil {
+		logger.Error("Failed to set up application", "error", err)
+		os.Exit(1)
+	}
+	if err := application.BootstrapAdmin(context.Background()); err != nil {
+		logger.Error("Failed to create admin account", "error", err)
+		os.Exit(1)
+	}
+	go jobs.PurgeDeletedProducts(context.Background(), application.Repositories.Product, application.Store, cfg.ProductRetention, cfg.ProductPurgeInterval)
+	go jobs.ReindexProducts(context.Background(), application.Repositories.Product, application.Search, cfg.SearchReindexInterval)
+	go jobs.ReleaseExpiredReservations(context.Background(), application.Repositories.Transaction.UsePrimary(), cfg.ReservationSweepInterval)
+	go jobs.ReconcileInventory(context.Background(), application.Repositories.Inventory.UsePrimary(), cfg.InventoryReconcileInterval)
+	repos := application.Repositories
+	go jobs.NewStockAlerter(repos.Inventory, repos.Product, repos.Notification, repos.JobCursor, application.Notifier).Run(context.Background(), cfg.StockAlertInterval)
+
+	err = application.Run()
+	if shutdownErr := application.Shutdown(context.Background()); shutdownErr != nil {
+		logger.Error("Failed to flush telemetry", "error", shutdownErr)
+	}
+	if err != nil {
+		logger.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
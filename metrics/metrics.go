@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Checkout failure reasons recorded by CheckoutFailed.
+const (
+	ReasonInvalidRequest      = "invalid_request"
+	ReasonProductNotFound     = "product_not_found"
+	ReasonVariantNotFound     = "variant_not_found"
+	ReasonInsufficientStock   = "insufficient_stock"
+	ReasonTransactionNotFound = "transaction_not_found"
+	ReasonTransactionStatus   = "transaction_status"
+	ReasonReservationExpired  = "reservation_expired"
+	ReasonInternalError       = "internal_error"
+)
+
+// Metrics owns a Prometheus registry and every collector the application
+// reports. Each App gets its own registry, so instances never share series.
+// All methods are safe to call on a nil *Metrics, which records nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequestDuration *prometheus.HistogramVec
+	httpRequests        *prometheus.CounterVec
+	dbQueryDuration     *prometheus.HistogramVec
+
+	transactionsCreated prometheus.Counter
+	transactionsPaid    prometheus.Counter
+	revenue             *prometheus.CounterVec
+	checkoutFailures    *prometheus.CounterVec
+	logins              *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route and status code.",
+		}, []string{"method", "route", "status"}),
+		dbQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Latency of GORM statements by operation and table.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+		transactionsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "shop_transactions_created_total",
+			Help: "Transactions created through checkout.",
+		}),
+		transactionsPaid: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "shop_transactions_paid_total",
+			Help: "Checkouts that were paid for.",
+		}),
+		revenue: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shop_revenue_total",
+			Help: "Sum of paid transaction totals by merchant.",
+		}, []string{"merchant_id"}),
+		checkoutFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shop_checkout_failures_total",
+			Help: "Rejected or failed checkouts by reason.",
+		}, []string{"reason"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shop_logins_total",
+			Help: "Login attempts by result.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequestDuration,
+		m.httpRequests,
+		m.dbQueryDuration,
+		m.transactionsCreated,
+		m.transactionsPaid,
+		m.revenue,
+		m.checkoutFailures,
+		m.logins,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Register adds extra collectors, such as a connection pool collector.
+func (m *Metrics) Register(cs ...prometheus.Collector) error {
+	if m == nil {
+		return nil
+	}
+	for _, c := range cs {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransactionCreated records a checkout that reserved stock and is awaiting
+// payment.
+func (m *Metrics) TransactionCreated() {
+	if m == nil {
+		return
+	}
+	m.transactionsCreated.Inc()
+}
+
+// PaymentCompleted records a paid checkout and its revenue.
+func (m *Metrics) PaymentCompleted(merchantID int64, total float64) {
+	if m == nil {
+		return
+	}
+	m.transactionsPaid.Inc()
+	m.revenue.WithLabelValues(strconv.FormatInt(merchantID, 10)).Add(total)
+}
+
+// CheckoutFailed records a checkout that did not produce a transaction, or
+// whose payment could not be completed.
+func (m *Metrics) CheckoutFailed(reason string) {
+	if m == nil {
+		return
+	}
+	m.checkoutFailures.WithLabelValues(reason).Inc()
+}
+
+// Login records a login attempt.
+func (m *Metrics) Login(succeeded bool) {
+	if m == nil {
+		return
+	}
+	result := "failed"
+	if succeeded {
+		result = "succeeded"
+	}
+	m.logins.WithLabelValues(result).Inc()
+}
@@ -2,17 +2,17 @@ package migrations
 
 import (
 	"backend-hanssen-hilman/models"
-	"fmt"
+	"log/slog"
 
 	"gorm.io/gorm"
 )
 
 // Migrate runs the database migrations.
 func Migrate(db *gorm.DB) {
-	fmt.Println("Running migrations...")
-	err := db.AutoMigrate(&models.User{}, &models.Product{}, &models.Transaction{})
+	slog.Info("Running migrations")
+	err := db.AutoMigrate(&models.User{}, &models.Category{}, &models.Product{}, &models.ProductCategory{}, &models.ProductVariant{}, &models.ProductImage{}, &models.Transaction{}, &models.ImportJob{}, &models.InventoryMovement{}, &models.StockReservation{}, &models.Notification{}, &models.NotificationPreference{}, &models.StockSubscription{}, &models.JobCursor{}, &models.Review{}, &models.ReviewFlag{}, &models.Wishlist{}, &models.WishlistItem{})
 	if err != nil {
 		panic("failed to migrate database")
 	}
-	fmt.Println("Migrations completed successfully.")
+	slog.Info("Migrations completed successfully")
 }
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Category is a node in the catalog taxonomy. Root categories have no parent,
+// and siblings are shown in Position order.
+type Category struct {
+	Id        int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ParentId  *int64    `gorm:"column:parent_id;index" json:"parent_id"`
+	Name      string    `gorm:"column:name;not null" json:"name"`
+	Slug      string    `gorm:"column:slug;size:100;not null;uniqueIndex" json:"slug"`
+	Position  int       `gorm:"column:position;not null;default:0" json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CategoryRequest struct {
+	Name     string `json:"name" binding:"required,notblank,max=100"`
+	Slug     string `json:"slug" binding:"required,slug,max=100"`
+	ParentId *int64 `json:"parent_id" binding:"omitempty,gt=0"`
+	Position int    `json:"position" binding:"gte=0"`
+}
+
+// CategoryNode is a category with its subtree. ProductCount is the number of
+// distinct listed products in the category or any of its descendants.
+type CategoryNode struct {
+	Category
+	ProductCount int64           `json:"product_count"`
+	Children     []*CategoryNode `json:"children"`
+}
+
+// ProductCategory is one row of the products/categories join table.
+type ProductCategory struct {
+	ProductId  int64 `gorm:"column:product_id;primaryKey"`
+	CategoryId int64 `gorm:"column:category_id;primaryKey;index"`
+}
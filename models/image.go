@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ProductImage is an uploaded picture of a product. The files live in the
+// blob store under Key and ThumbnailKey; URL and ThumbnailURL are filled in
+// for responses.
+type ProductImage struct {
+	Id           int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ProductId    int64     `gorm:"column:product_id;not null;index" json:"product_id"`
+	Key          string    `gorm:"column:blob_key;not null" json:"-"`
+	ThumbnailKey string    `gorm:"column:thumbnail_key;not null" json:"-"`
+	ContentType  string    `gorm:"column:content_type;not null" json:"content_type"`
+	Size         int64     `gorm:"column:size;not null" json:"size"`
+	Width        int       `gorm:"column:width;not null" json:"width"`
+	Height       int       `gorm:"column:height;not null" json:"height"`
+	Position     int       `gorm:"column:position;not null;default:0" json:"position"`
+	IsPrimary    bool      `gorm:"column:is_primary;not null;default:false" json:"is_primary"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	URL          string `gorm:"-" json:"url"`
+	ThumbnailURL string `gorm:"-" json:"thumbnail_url"`
+}
+
+// ImageOrderRequest lists every image of a product in the new display order.
+type ImageOrderRequest struct {
+	ImageIds []int64 `json:"image_ids" binding:"required,min=1,unique,dive,gt=0"`
+}
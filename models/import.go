@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// Values of ImportJob.Status.
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// ImportJob tracks a bulk product import running in the background. In a
+// dry run every row is validated and counted but nothing is written.
+type ImportJob struct {
+	Id         int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	MerchantId int64  `gorm:"column:merchant_id;not null;index" json:"-"`
+	Format     string `gorm:"column:format;size:16;not null" json:"format"`
+	DryRun     bool   `gorm:"column:dry_run;not null;default:false" json:"dry_run"`
+	Status     string `gorm:"column:status;size:16;not null" json:"status"`
+
+	Processed int64 `gorm:"column:processed;not null;default:0" json:"processed"`
+	Created   int64 `gorm:"column:created;not null;default:0" json:"created"`
+	Updated   int64 `gorm:"column:updated;not null;default:0" json:"updated"`
+	Failed    int64 `gorm:"column:failed;not null;default:0" json:"failed"`
+
+	// Errors holds the first MaxImportErrors row errors. Message explains
+	// why a failed job stopped early.
+	Errors          []ImportRowError `gorm:"column:errors;serializer:json" json:"errors"`
+	ErrorsTruncated bool             `gorm:"column:errors_truncated;not null;default:false" json:"errors_truncated"`
+	Message         string           `gorm:"column:message" json:"message,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at"`
+}
+
+// MaxImportErrors bounds how many row errors a job keeps.
+const MaxImportErrors = 1000
+
+// ImportRowError is one problem with one row of an import file. Rows are
+// numbered from 1, not counting the CSV header.
+type ImportRowError struct {
+	Row     int64  `json:"row"`
+	Sku     string `json:"sku,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ProductImportRow is one product in an import or export file. Rows are
+// matched to the merchant's products by SKU.
+type ProductImportRow struct {
+	Sku         string   `json:"sku" binding:"required,notblank,max=64"`
+	Name        string   `json:"name" binding:"required,notblank,max=255"`
+	Description string   `json:"description" binding:"max=5000"`
+	Price       *float64 `json:"price" binding:"required,money"`
+	Quantity    *int64   `json:"quantity" binding:"required,gte=0"`
+	// Categories are category slugs. Nil, as opposed to empty, keeps the
+	// categories of an existing product.
+	Categories *[]string `json:"categories" binding:"omitempty,max=20,dive,slug"`
+}
+
+// ProductImportQuery holds the query parameters of an import. Without a
+// format, the one named by the Content-Type is used.
+type ProductImportQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"`
+	DryRun bool   `form:"dry_run"`
+}
+
+type ProductExportQuery struct {
+	Format string `form:"format,default=csv" binding:"oneof=csv ndjson"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Values of InventoryMovement.Reason.
+const (
+	MovementRestock     = "restock"
+	MovementSale        = "sale"
+	MovementRefund      = "refund"
+	MovementAdjustment  = "adjustment"
+	MovementReservation = "reservation"
+	MovementRelease     = "release"
+)
+
+// InventoryMovement is one entry of the append-only stock ledger. Delta is
+// the signed change to the available quantity of the product, or of the
+// variant when VariantId is set, and Balance is that quantity right after
+// it. Stock counts are kept equal to the sum of their movements.
+type InventoryMovement struct {
+	Id            int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ProductId     int64     `gorm:"column:product_id;not null;index" json:"product_id"`
+	VariantId     *int64    `gorm:"column:variant_id;index" json:"variant_id"`
+	Reason        string    `gorm:"column:reason;size:16;not null" json:"reason"`
+	Delta         int64     `gorm:"column:delta;not null" json:"delta"`
+	Balance       int64     `gorm:"column:balance;not null" json:"balance"`
+	TransactionId *int64    `gorm:"column:transaction_id;index" json:"transaction_id"`
+	Note          string    `gorm:"column:note;size:255" json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Values of StockReservation.Status.
+const (
+	ReservationActive   = "active"
+	ReservationConsumed = "consumed"
+	ReservationReleased = "released"
+)
+
+// StockReservation holds stock for a pending transaction until it is paid
+// for or ExpiresAt passes, after which the sweeper releases it.
+type StockReservation struct {
+	Id            int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	TransactionId int64     `gorm:"column:transaction_id;not null;uniqueIndex" json:"transaction_id"`
+	ProductId     int64     `gorm:"column:product_id;not null" json:"product_id"`
+	VariantId     *int64    `gorm:"column:variant_id" json:"variant_id"`
+	Quantity      int64     `gorm:"column:quantity;not null" json:"quantity"`
+	Status        string    `gorm:"column:status;size:16;not null;index:idx_stock_reservations_expiry,priority:1" json:"status"`
+	ExpiresAt     time.Time `gorm:"column:expires_at;not null;index:idx_stock_reservations_expiry,priority:2" json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// InventoryAdjustmentRequest records a restock or a manual correction.
+// VariantId is required for products with variants.
+type InventoryAdjustmentRequest struct {
+	VariantId *int64 `json:"variant_id" binding:"omitempty,gt=0"`
+	Reason    string `json:"reason" binding:"required,oneof=restock adjustment"`
+	Delta     int64  `json:"delta" binding:"required,ne=0"`
+	Note      string `json:"note" binding:"max=255"`
+}
+
+type InventoryMovementQuery struct {
+	Page      int    `form:"page" binding:"omitempty,min=1"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1"`
+	VariantId int64  `form:"variant_id" binding:"omitempty,gt=0"`
+	Reason    string `form:"reason" binding:"omitempty,oneof=restock sale refund adjustment reservation release"`
+}
+
+type PaginatedInventoryMovementResponse struct {
+	Movements    []InventoryMovement `json:"movements"`
+	TotalRecords int64               `json:"total_records"`
+	CurrentPage  int                 `json:"current_page"`
+	PageSize     int                 `json:"page_size"`
+	TotalPages   int                 `json:"total_pages"`
+}
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// Kinds of notification.
+const (
+	NotificationLowStock    = "low_stock"
+	NotificationOutOfStock  = "out_of_stock"
+	NotificationBackInStock = "back_in_stock"
+)
+
+// Notification is a message kept for a user's in-app inbox. Data holds
+// machine-readable details such as the product ID; ProductId repeats it so
+// the notifications of a purged product can be found.
+type Notification struct {
+	Id        int64          `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId    int64          `gorm:"column:user_id;not null;index" json:"-"`
+	ProductId *int64         `gorm:"column:product_id;index" json:"-"`
+	Kind      string         `gorm:"column:kind;size:32;not null" json:"kind"`
+	Title     string         `gorm:"column:title;not null" json:"title"`
+	Body      string         `gorm:"column:body;not null" json:"body"`
+	Data      map[string]any `gorm:"column:data;serializer:json" json:"data"`
+	ReadAt    *time.Time     `gorm:"column:read_at" json:"read_at"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// NotificationPreference says how a user wants to be notified besides the
+// in-app inbox, which always receives everything. Users without a row get
+// email only.
+type NotificationPreference struct {
+	UserId       int64     `gorm:"column:user_id;primaryKey" json:"-"`
+	EmailEnabled bool      `gorm:"column:email_enabled;not null" json:"email_enabled"`
+	WebhookUrl   string    `gorm:"column:webhook_url;size:500" json:"webhook_url"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type NotificationPreferenceRequest struct {
+	EmailEnabled *bool  `json:"email_enabled" binding:"required"`
+	WebhookUrl   string `json:"webhook_url" binding:"omitempty,http_url,max=500"`
+}
+
+type NotificationQuery struct {
+	Page   int  `form:"page" binding:"omitempty,min=1"`
+	Limit  int  `form:"limit" binding:"omitempty,min=1"`
+	Unread bool `form:"unread"`
+}
+
+type PaginatedNotificationResponse struct {
+	Notifications []Notification `json:"notifications"`
+	TotalRecords  int64          `json:"total_records"`
+	Unread        int64          `json:"unread"`
+	CurrentPage   int            `json:"current_page"`
+	PageSize      int            `json:"page_size"`
+	TotalPages    int            `json:"total_pages"`
+}
+
+// StockSubscription asks for a back-in-stock notification for a product, or
+// one of its variants, that is sold out. It is kept after it fires, with
+// NotifiedAt set, until the customer removes or renews it.
+type StockSubscription struct {
+	Id         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	CustomerId int64      `gorm:"column:customer_id;not null;uniqueIndex:idx_stock_subscriptions_item,priority:3" json:"-"`
+	ProductId  int64      `gorm:"column:product_id;not null;uniqueIndex:idx_stock_subscriptions_item,priority:1" json:"product_id"`
+	VariantId  int64      `gorm:"column:variant_id;not null;default:0;uniqueIndex:idx_stock_subscriptions_item,priority:2" json:"variant_id"`
+	NotifiedAt *time.Time `gorm:"column:notified_at" json:"notified_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// StockSubscriptionRequest picks the variant to watch. It is required for
+// products with variants.
+type StockSubscriptionRequest struct {
+	VariantId int64 `json:"variant_id" form:"variant_id" binding:"omitempty,gt=0"`
+}
+
+// JobCursor records how far a background job has read an append-only
+// table, so instances sharing the database resume and split the work.
+type JobCursor struct {
+	Name      string    `gorm:"column:name;primaryKey;size:64"`
+	Position  int64     `gorm:"column:position;not null"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
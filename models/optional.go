@@ -0,0 +1,22 @@
+package models
+
+import "encoding/json"
+
+// Optional is a JSON field that distinguishes "absent" from "null" from a
+// value, as JSON Merge Patch (RFC 7396) requires: an absent member leaves the
+// target unchanged, null removes it, and any other value replaces it.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// UnmarshalJSON is only called when the member is present in the document.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
@@ -0,0 +1,28 @@
+package models
+
+// Cursor is a position in a keyset-paginated listing: the sort key values
+// and ID of the row at the edge of a page. A backward cursor pages towards
+// the start of the listing.
+type Cursor struct {
+	Values   []float64 `json:"v,omitempty"`
+	Id       int64     `json:"id"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+// Page is one page of a listing. Next and Prev are nil at either end.
+type Page[T any] struct {
+	Items []T
+	Total int64
+	Next  *Cursor
+	Prev  *Cursor
+}
+
+// PageLinks lead to the neighbouring pages of a listing. The cursors are
+// opaque tokens for the cursor query parameter, and the links are the
+// request URL with them applied.
+type PageLinks struct {
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
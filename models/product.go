@@ -1,48 +1,201 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Product struct {
 	Id          int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
 	Name        string    `gorm:"column:name" json:"name"`
 	Description string    `gorm:"column:description" json:"description"`
 	Price       float64   `gorm:"column:price" json:"price"`
-	MerchantId  int64     `gorm:"column:merchant_id" json:"merchant_id"`
+	MerchantId  int64     `gorm:"column:merchant_id;uniqueIndex:idx_products_merchant_sku" json:"merchant_id"`
 	Quantity    int64     `gorm:"column:quantity" json:"quantity"`
+	Version     int64     `gorm:"column:version;not null;default:1" json:"version"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Sku is the merchant's own identifier for the product, unique among
+	// their products. Bulk imports match rows to products by it.
+	Sku *string `gorm:"column:sku;size:64;uniqueIndex:idx_products_merchant_sku" json:"sku"`
+
+	// LowStockThreshold alerts the merchant once the stock of the product, or
+	// of any of its variants, drops to it or below. Nil turns alerts off;
+	// running out is always reported.
+	LowStockThreshold *int64 `gorm:"column:low_stock_threshold" json:"low_stock_threshold"`
+
+	// Archived products stay purchasable by ID but are left out of the catalog.
+	Archived  bool           `gorm:"column:archived;not null;default:false" json:"archived"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	// Options names the axes, such as size and colour, that the variants
+	// differ in. Products with variants keep their stock on the variants.
+	Options  []string         `gorm:"column:options;serializer:json" json:"options"`
+	Variants []ProductVariant `gorm:"foreignKey:ProductId" json:"variants"`
+	Images   []ProductImage   `gorm:"foreignKey:ProductId" json:"images"`
+
+	Categories []Category `gorm:"many2many:product_categories;joinForeignKey:ProductId;joinReferences:CategoryId" json:"categories"`
+
+	// RatingAvg and RatingCount summarise the product's reviews so listings
+	// can sort by rating without aggregating them on every request.
+	RatingAvg   float64 `gorm:"column:rating_avg;not null;default:0;index" json:"rating_avg"`
+	RatingCount int64   `gorm:"column:rating_count;not null;default:0" json:"rating_count"`
 }
 
 type ProductDetail struct {
 	Product
 	MerchantName string `gorm:"column:merchant_name" json:"merchant_name"`
+
+	// UnitsSold is only loaded when listing by best selling.
+	UnitsSold int64 `gorm:"column:units_sold;->" json:"-"`
 }
 
-type ProductRequest struct {
-	Name         string  `form:"name" json:"name"`
-	Description  string  `form:"description" json:"description"`
-	MerchantName string  `form:"merchant_name" json:"merchant_name"`
-	Price        float64 `form:"price" json:"price"`
-	MinPrice     float64 `form:"min_price" json:"min_price"`
-	MaxPrice     float64 `form:"max_price" json:"max_price"`
-	Quantity     int64   `form:"quantity" json:"quantity"`
-	Page         int     `form:"page"`
-	Limit        int     `form:"limit"`
+type ProductCreateRequest struct {
+	Sku               string   `json:"sku" binding:"omitempty,notblank,max=64"`
+	Name              string   `json:"name" binding:"required,notblank,max=255"`
+	Description       string   `json:"description" binding:"max=5000"`
+	Price             *float64 `json:"price" binding:"required,money"`
+	Quantity          int64    `json:"quantity" binding:"gte=0"`
+	Options           []string `json:"options" binding:"max=3,unique,dive,notblank,max=50"`
+	CategoryIds       []int64  `json:"category_ids" binding:"max=20,dive,gt=0"`
+	LowStockThreshold *int64   `json:"low_stock_threshold" binding:"omitempty,gte=0"`
 }
 
+// ProductReplaceRequest is the body of PUT: every field is replaced, and an
+// omitted description is cleared.
+type ProductReplaceRequest struct {
+	Sku               string   `json:"sku" binding:"omitempty,notblank,max=64"`
+	Name              string   `json:"name" binding:"required,notblank,max=255"`
+	Description       string   `json:"description" binding:"max=5000"`
+	Price             *float64 `json:"price" binding:"required,money"`
+	Quantity          *int64   `json:"quantity" binding:"required,gte=0"`
+	Options           []string `json:"options" binding:"max=3,unique,dive,notblank,max=50"`
+	CategoryIds       []int64  `json:"category_ids" binding:"max=20,dive,gt=0"`
+	LowStockThreshold *int64   `json:"low_stock_threshold" binding:"omitempty,gte=0"`
+}
+
+// ProductPatchRequest is a JSON Merge Patch document for PATCH. The patched
+// product is validated as a ProductReplaceRequest.
+type ProductPatchRequest struct {
+	Sku               Optional[string]   `json:"sku"`
+	Name              Optional[string]   `json:"name"`
+	Description       Optional[string]   `json:"description"`
+	Price             Optional[float64]  `json:"price"`
+	Quantity          Optional[int64]    `json:"quantity"`
+	Options           Optional[[]string] `json:"options"`
+	CategoryIds       Optional[[]int64]  `json:"category_ids"`
+	LowStockThreshold Optional[int64]    `json:"low_stock_threshold"`
+}
+
+type ProductQuery struct {
+	Name         string  `form:"name" binding:"max=255"`
+	Description  string  `form:"description" binding:"max=255"`
+	MerchantName string  `form:"merchant_name" binding:"max=255"`
+	Price        float64 `form:"price" binding:"omitempty,money"`
+	MinPrice     float64 `form:"min_price" binding:"omitempty,money"`
+	MaxPrice     float64 `form:"max_price" binding:"omitempty,money,gtefield=MinPrice"`
+	Page         int     `form:"page" binding:"omitempty,min=1"`
+	Limit        int     `form:"limit" binding:"omitempty,min=1"`
+
+	// Category is a category slug; products in its subcategories match too.
+	// The controller resolves it into CategoryIds.
+	Category    string  `form:"category" binding:"max=100"`
+	CategoryIds []int64 `form:"-"`
+
+	// Q is a free-text search over name and description. The controller
+	// resolves it into SearchIds, every hit best match first, and results
+	// keep that order.
+	Q         string  `form:"q" binding:"max=200"`
+	SearchIds []int64 `form:"-"`
+
+	// Sort is one of the ProductSort values. It defaults to newest first,
+	// or to best match first when searching.
+	Sort       string `form:"sort" binding:"omitempty,oneof=price_asc price_desc newest best_selling rating"`
+	InStock    bool   `form:"in_stock"`
+	MerchantId int64  `form:"merchant_id" binding:"omitempty,gt=0"`
+
+	// Cursor is a token from an earlier page's links. It takes precedence
+	// over Page, and the controller decodes it into Position.
+	Cursor   string  `form:"cursor" binding:"max=512"`
+	Position *Cursor `form:"-"`
+}
+
+// Accepted values of ProductQuery.Sort.
+const (
+	ProductSortPriceAsc    = "price_asc"
+	ProductSortPriceDesc   = "price_desc"
+	ProductSortNewest      = "newest"
+	ProductSortBestSelling = "best_selling"
+	ProductSortRating      = "rating"
+)
+
 type ProductResponse struct {
-	Id           int64   `json:"id"`
-	Name         string  `json:"name"`
-	Description  string  `json:"description"`
-	Price        float64 `json:"price"`
-	MerchantName string  `json:"merchant_name"`
-	Quantity     int64   `json:"quantity"`
+	Id           int64            `json:"id"`
+	Sku          *string          `json:"sku"`
+	Name         string           `json:"name"`
+	Description  string           `json:"description"`
+	Price        float64          `json:"price"`
+	MerchantName string           `json:"merchant_name"`
+	Quantity     int64            `json:"quantity"`
+	Archived     bool             `json:"archived"`
+	Options      []string         `json:"options"`
+	Variants     []ProductVariant `json:"variants"`
+	Images       []ProductImage   `json:"images"`
+	Categories   []Category       `json:"categories"`
+	RatingAvg    float64          `json:"rating_avg"`
+	RatingCount  int64            `json:"rating_count"`
+
+	// Highlights is only set for search results.
+	Highlights *SearchHighlights `json:"highlights,omitempty"`
+}
+
+// SearchHighlights are HTML excerpts of the fields a search matched, with
+// the matching words wrapped in <mark>.
+type SearchHighlights struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
 type PaginatedProductResponse struct {
 	Products     []ProductResponse `json:"products"`
 	TotalRecords int64             `json:"total_records"`
-	CurrentPage  int               `json:"current_page"`
+	CurrentPage  int               `json:"current_page,omitempty"`
 	PageSize     int               `json:"page_size"`
 	TotalPages   int               `json:"total_pages"`
+	Links        PageLinks         `json:"links"`
+	Facets       *ProductFacets    `json:"facets,omitempty"`
+}
+
+// ProductFacets counts the products matching a listing query along each
+// filter dimension. Every dimension ignores its own filter, so the counts
+// show what choosing another value there would return.
+type ProductFacets struct {
+	Prices     []PriceFacet    `json:"prices"`
+	Merchants  []MerchantFacet `json:"merchants"`
+	Categories []CategoryFacet `json:"categories"`
+}
+
+// PriceFacet counts products priced from Min up to but excluding Max. The
+// last bucket has no Max.
+type PriceFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int64    `json:"count"`
+}
+
+type MerchantFacet struct {
+	MerchantId   int64  `gorm:"column:merchant_id" json:"merchant_id"`
+	MerchantName string `gorm:"column:merchant_name" json:"merchant_name"`
+	Count        int64  `gorm:"column:count" json:"count"`
+}
+
+// CategoryFacet counts products in the category or any of its descendants.
+type CategoryFacet struct {
+	CategoryId int64  `json:"category_id"`
+	ParentId   *int64 `json:"parent_id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	Count      int64  `json:"count"`
 }
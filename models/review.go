@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// Values of Review.Status. Hidden reviews are left out of listings and of
+// the product's rating.
+const (
+	ReviewPublished = "published"
+	ReviewHidden    = "hidden"
+)
+
+// Review is a customer's rating of a product they bought, with an optional
+// reply from the merchant. Customers review each product once.
+type Review struct {
+	Id         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ProductId  int64      `gorm:"column:product_id;not null;uniqueIndex:idx_reviews_product_customer,priority:1" json:"product_id"`
+	CustomerId int64      `gorm:"column:customer_id;not null;uniqueIndex:idx_reviews_product_customer,priority:2" json:"customer_id"`
+	Rating     int        `gorm:"column:rating;not null" json:"rating"`
+	Body       string     `gorm:"column:body;size:5000" json:"body"`
+	Status     string     `gorm:"column:status;size:16;not null;default:published;index" json:"status"`
+	Reply      *string    `gorm:"column:reply;size:2000" json:"reply"`
+	RepliedAt  *time.Time `gorm:"column:replied_at" json:"replied_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type ReviewDetail struct {
+	Review
+	CustomerName string `gorm:"column:customer_name" json:"customer_name"`
+}
+
+// FlaggedReview is a review waiting for moderation with the number of
+// open flags against it.
+type FlaggedReview struct {
+	ReviewDetail
+	Flags int64 `gorm:"column:flags" json:"flags"`
+}
+
+// ReviewFlag reports a review to the moderators. Each user flags a review
+// at most once; moderating the review resolves its flags.
+type ReviewFlag struct {
+	Id         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ReviewId   int64      `gorm:"column:review_id;not null;uniqueIndex:idx_review_flags_review_user,priority:1" json:"review_id"`
+	UserId     int64      `gorm:"column:user_id;not null;uniqueIndex:idx_review_flags_review_user,priority:2" json:"-"`
+	Reason     string     `gorm:"column:reason;size:32;not null" json:"reason"`
+	Note       string     `gorm:"column:note;size:500" json:"note"`
+	ResolvedAt *time.Time `gorm:"column:resolved_at;index" json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ReviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Body   string `json:"body" binding:"max=5000"`
+}
+
+type ReviewReplyRequest struct {
+	Reply string `json:"reply" binding:"required,notblank,max=2000"`
+}
+
+type ReviewFlagRequest struct {
+	Reason string `json:"reason" binding:"required,oneof=spam offensive off_topic fake other"`
+	Note   string `json:"note" binding:"max=500"`
+}
+
+type ReviewModerationRequest struct {
+	Action string `json:"action" binding:"required,oneof=hide publish"`
+}
+
+type ReviewQuery struct {
+	Page   int `form:"page" binding:"omitempty,min=1"`
+	Limit  int `form:"limit" binding:"omitempty,min=1"`
+	Rating int `form:"rating" binding:"omitempty,min=1,max=5"`
+
+	// Sort is one of the ReviewSort values, newest first by default.
+	Sort string `form:"sort" binding:"omitempty,oneof=newest oldest rating_desc rating_asc"`
+}
+
+// Accepted values of ReviewQuery.Sort.
+const (
+	ReviewSortNewest     = "newest"
+	ReviewSortOldest     = "oldest"
+	ReviewSortRatingDesc = "rating_desc"
+	ReviewSortRatingAsc  = "rating_asc"
+)
+
+type PaginatedReviewResponse struct {
+	Reviews      []ReviewDetail `json:"reviews"`
+	RatingAvg    float64        `json:"rating_avg"`
+	RatingCount  int64          `json:"rating_count"`
+	TotalRecords int64          `json:"total_records"`
+	CurrentPage  int            `json:"current_page"`
+	PageSize     int            `json:"page_size"`
+	TotalPages   int            `json:"total_pages"`
+}
+
+type PaginatedFlaggedReviewResponse struct {
+	Reviews      []FlaggedReview `json:"reviews"`
+	TotalRecords int64           `json:"total_records"`
+	CurrentPage  int             `json:"current_page"`
+	PageSize     int             `json:"page_size"`
+	TotalPages   int             `json:"total_pages"`
+}
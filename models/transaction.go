@@ -5,29 +5,77 @@ import "time"
 type Transaction struct {
 	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
 	ProductId  int64     `gorm:"column:product_id" json:"product_id"`
+	VariantId  *int64    `gorm:"column:variant_id;index" json:"variant_id"`
+	Sku        string    `gorm:"column:sku" json:"sku"`
 	Quantity   int64     `gorm:"column:quantity" json:"quantity"`
 	TotalPrice float64   `gorm:"column:total_price" json:"total_price"`
 	CustomerId int64     `gorm:"column:customer_id" json:"customer_id"`
+	Status     string    `gorm:"column:status;not null;default:completed;index" json:"status"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// Values of Transaction.Status. A checkout stays pending, holding a stock
+// reservation, until it is paid for or the reservation runs out.
+const (
+	TransactionStatusPending   = "pending"
+	TransactionStatusCompleted = "completed"
+	TransactionStatusCancelled = "cancelled"
+	TransactionStatusRefunded  = "refunded"
+)
+
+// TransactionRequest buys Quantity of a product. VariantId is required for
+// products that have variants.
 type TransactionRequest struct {
-	ProductId  int64 `json:"product_id"`
-	Quantity   int64 `json:"quantity"`
-	CustomerId int64 `json:"customer_id"`
-	Page       int   `form:"page"`
-	Limit      int   `form:"limit"`
+	ProductId int64  `json:"product_id" binding:"required,gt=0"`
+	VariantId *int64 `json:"variant_id" binding:"omitempty,gt=0"`
+	Quantity  int64  `json:"quantity" binding:"required,gt=0"`
+}
+
+type TransactionQuery struct {
+	Page  int `form:"page" binding:"omitempty,min=1"`
+	Limit int `form:"limit" binding:"omitempty,min=1"`
+
+	// Cursor is a token from an earlier page's links. It takes precedence
+	// over Page, and the controller decodes it into Position.
+	Cursor   string  `form:"cursor" binding:"max=512"`
+	Position *Cursor `form:"-"`
+
+	// From and To bound created_at (RFC 3339, both inclusive).
+	From *time.Time `form:"from"`
+	To   *time.Time `form:"to" binding:"omitempty,gtefield=From"`
+
+	ProductId int64   `form:"product_id" binding:"omitempty,gt=0"`
+	Status    string  `form:"status" binding:"omitempty,oneof=pending completed cancelled refunded"`
+	MinAmount float64 `form:"min_amount" binding:"omitempty,money"`
+	MaxAmount float64 `form:"max_amount" binding:"omitempty,money,gtefield=MinAmount"`
+	// CustomerId only applies to the merchant listing.
+	CustomerId int64 `form:"customer_id" binding:"omitempty,gt=0"`
+
+	// Sort is one of the TransactionSort values, newest first by default.
+	Sort string `form:"sort" binding:"omitempty,oneof=newest oldest amount_asc amount_desc"`
 }
 
+// Accepted values of TransactionQuery.Sort.
+const (
+	TransactionSortNewest     = "newest"
+	TransactionSortOldest     = "oldest"
+	TransactionSortAmountAsc  = "amount_asc"
+	TransactionSortAmountDesc = "amount_desc"
+)
+
 type TransactionResponse struct {
 	Id          int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
 	ProductId   int64     `gorm:"column:product_id" json:"product_id"`
 	ProductName string    `gorm:"column:product_name" json:"product_name"`
+	VariantId   *int64    `gorm:"column:variant_id" json:"variant_id"`
+	Sku         string    `gorm:"column:sku" json:"sku"`
 	Quantity    int64     `gorm:"column:quantity" json:"quantity"`
 	TotalPrice  float64   `gorm:"column:total_price" json:"total_price"`
 	Customer    string    `gorm:"column:customer" json:"customer"`
 	Merchant    string    `gorm:"column:merchant" json:"merchant"`
+	MerchantId  int64     `gorm:"column:merchant_id" json:"-"`
+	Status      string    `gorm:"column:status" json:"status"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -35,7 +83,8 @@ type TransactionResponse struct {
 type PaginatedTransactionResponse struct {
 	Transactions []TransactionResponse `json:"transactions"`
 	TotalRecords int64                 `json:"total_records"`
-	CurrentPage  int                   `json:"current_page"`
+	CurrentPage  int                   `json:"current_page,omitempty"`
 	PageSize     int                   `json:"page_size"`
 	TotalPages   int                   `json:"total_pages"`
+	Links        PageLinks             `json:"links"`
 }
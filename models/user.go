@@ -16,15 +16,15 @@ type UserRequest struct {
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
 }
 
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
+	Name     string `json:"name" binding:"required,notblank,max=100"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,password"`
+	Role     string `json:"role" binding:"omitempty,oneof=merchant customer"`
 }
 
 type LoginResponse struct {
@@ -34,7 +34,3 @@ type LoginResponse struct {
 type RegisterResponse struct {
 	Message string `json:"message"`
 }
-
-type ErrorResponse struct {
-	Message string `json:"message"`
-}
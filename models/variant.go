@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// ProductVariant is one purchasable combination of a product's options, such
+// as size M in red. It has its own SKU and stock and may override the price.
+type ProductVariant struct {
+	Id        int64             `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ProductId int64             `gorm:"column:product_id;not null;uniqueIndex:idx_product_variants_sku" json:"product_id"`
+	Sku       string            `gorm:"column:sku;size:64;not null;uniqueIndex:idx_product_variants_sku" json:"sku"`
+	Options   map[string]string `gorm:"column:options;serializer:json" json:"options"`
+	Price     *float64          `gorm:"column:price" json:"price"`
+	Quantity  int64             `gorm:"column:quantity;not null;default:0" json:"quantity"`
+	Version   int64             `gorm:"column:version;not null;default:1" json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// UnitPrice is the variant's price override, or basePrice when it has none.
+func (v *ProductVariant) UnitPrice(basePrice float64) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return basePrice
+}
+
+// VariantRequest creates or replaces a variant. Options must name a value for
+// every option axis of the product.
+type VariantRequest struct {
+	Sku      string            `json:"sku" binding:"required,notblank,max=64"`
+	Options  map[string]string `json:"options" binding:"max=3,dive,keys,notblank,max=50,endkeys,notblank,max=50"`
+	Price    *float64          `json:"price" binding:"omitempty,money"`
+	Quantity *int64            `json:"quantity" binding:"required,gte=0"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Wishlist is a customer's named list of products to buy later. Public
+// lists can be viewed by other customers.
+type Wishlist struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	CustomerId int64     `gorm:"column:customer_id;not null;uniqueIndex:idx_wishlists_customer_name,priority:1" json:"customer_id"`
+	Name       string    `gorm:"column:name;size:100;not null;uniqueIndex:idx_wishlists_customer_name,priority:2" json:"name"`
+	Public     bool      `gorm:"column:public;not null;default:false" json:"public"`
+	ItemCount  int64     `gorm:"column:item_count;->" json:"item_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WishlistItem saves a product, or one of its variants, to a wishlist.
+// PriceAtAdd is the unit price when it was saved, which price drops are
+// measured against.
+type WishlistItem struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	WishlistId int64     `gorm:"column:wishlist_id;not null;uniqueIndex:idx_wishlist_items_item,priority:1" json:"wishlist_id"`
+	ProductId  int64     `gorm:"column:product_id;not null;uniqueIndex:idx_wishlist_items_item,priority:2;index" json:"product_id"`
+	VariantId  int64     `gorm:"column:variant_id;not null;default:0;uniqueIndex:idx_wishlist_items_item,priority:3" json:"variant_id"`
+	Quantity   int64     `gorm:"column:quantity;not null;default:1" json:"quantity"`
+	PriceAtAdd float64   `gorm:"column:price_at_add;not null" json:"price_at_add"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WishlistRequest struct {
+	Name   string `json:"name" binding:"required,notblank,max=100"`
+	Public bool   `json:"public"`
+}
+
+// WishlistItemRequest saves a product to a wishlist. VariantId is required
+// for products with variants. Saving an item again updates its quantity.
+type WishlistItemRequest struct {
+	ProductId int64 `json:"product_id" binding:"required,gt=0"`
+	VariantId int64 `json:"variant_id" binding:"omitempty,gt=0"`
+	Quantity  int64 `json:"quantity" binding:"omitempty,gt=0,max=1000"`
+}
+
+// WishlistPurchaseRequest checks out the listed items of a wishlist, or all
+// of them when ItemIds is empty.
+type WishlistPurchaseRequest struct {
+	ItemIds []int64 `json:"item_ids" binding:"max=100,unique,dive,gt=0"`
+}
+
+// WishlistItemResponse is a saved item with its current price. PriceDrop is
+// how much cheaper it has become since it was saved, or 0.
+type WishlistItemResponse struct {
+	WishlistItem
+	ProductName  string  `json:"product_name"`
+	Sku          *string `json:"sku"`
+	CurrentPrice float64 `json:"current_price"`
+	PriceDrop    float64 `json:"price_drop"`
+	PriceDropped bool    `json:"price_dropped"`
+	InStock      bool    `json:"in_stock"`
+}
+
+type WishlistResponse struct {
+	Wishlist
+	Items []WishlistItemResponse `json:"items"`
+}
@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InAppChannel stores notifications in the user's inbox.
+type InAppChannel struct {
+	notifications repositories.NotificationRepository
+}
+
+func NewInAppChannel(notifications repositories.NotificationRepository) *InAppChannel {
+	return &InAppChannel{notifications: notifications}
+}
+
+func (c *InAppChannel) Name() string { return "in_app" }
+
+func (c *InAppChannel) Send(ctx context.Context, _ Recipient, notification *models.Notification) error {
+	return c.notifications.CreateNotification(ctx, notification)
+}
+
+// EmailChannel sends plain-text email over SMTP.
+type EmailChannel struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewEmailChannel(cfg *Config) *EmailChannel {
+	channel := &EmailChannel{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		from: cfg.SMTPFrom,
+	}
+	if cfg.SMTPUsername != "" {
+		channel.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return channel
+}
+
+func (c *EmailChannel) Name() string { return "email" }
+
+func (c *EmailChannel) Send(_ context.Context, to Recipient, notification *models.Notification) error {
+	if to.Email == "" {
+		return nil
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", c.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(notification.Body)
+	msg.WriteString("\r\n")
+
+	return smtp.SendMail(c.addr, c.auth, c.from, []string{to.Email}, []byte(msg.String()))
+}
+
+// WebhookChannel POSTs notifications as JSON to the URL a user configured.
+// With a secret, the X-Webhook-Signature header carries "sha256=" and the
+// hex HMAC-SHA256 of the body, so receivers can check where it came from.
+type WebhookChannel struct {
+	client *http.Client
+	secret []byte
+}
+
+func NewWebhookChannel(secret string, timeout time.Duration) *WebhookChannel {
+	return &WebhookChannel{client: &http.Client{Timeout: timeout}, secret: []byte(secret)}
+}
+
+func (c *WebhookChannel) Name() string { return "webhook" }
+
+type webhookPayload struct {
+	Kind   string         `json:"kind"`
+	UserId int64          `json:"user_id"`
+	Title  string         `json:"title"`
+	Body   string         `json:"body"`
+	Data   map[string]any `json:"data"`
+	SentAt time.Time      `json:"sent_at"`
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, to Recipient, notification *models.Notification) error {
+	if to.WebhookUrl == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		Kind:   notification.Kind,
+		UserId: to.UserId,
+		Title:  notification.Title,
+		Body:   notification.Body,
+		Data:   notification.Data,
+		SentAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to.WebhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", notification.Kind)
+	if len(c.secret) > 0 {
+		mac := hmac.New(sha256.New, c.secret)
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
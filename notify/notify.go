@@ -0,0 +1,110 @@
+// Package notify delivers notifications to users through in-app, email and
+// webhook channels.
+package notify
+
+import (
+	"backend-hanssen-hilman/models"
+	"backend-hanssen-hilman/repositories"
+	"backend-hanssen-hilman/util"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Notifier sends a notification to the user named by its UserId.
+type Notifier interface {
+	Notify(ctx context.Context, notification *models.Notification) error
+}
+
+// Recipient is where a user can be reached. Empty fields mean the channel
+// doesn't apply to them.
+type Recipient struct {
+	UserId     int64
+	Name       string
+	Email      string
+	WebhookUrl string
+}
+
+// Channel delivers notifications one way. Channels skip recipients they
+// can't reach rather than failing.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, to Recipient, notification *models.Notification) error
+}
+
+type Config struct {
+	// SMTPHost enables email; without it only the other channels are used.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// WebhookSecret signs webhook payloads; see WebhookChannel.
+	WebhookSecret  string
+	WebhookTimeout time.Duration
+}
+
+// BuildConfig to set value of Config
+func BuildConfig() *Config {
+	return &Config{
+		SMTPHost:       os.Getenv("SMTP_HOST"),
+		SMTPPort:       util.GetEnvInt("SMTP_PORT", 587),
+		SMTPUsername:   os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:   os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:       os.Getenv("SMTP_FROM"),
+		WebhookSecret:  os.Getenv("WEBHOOK_SECRET"),
+		WebhookTimeout: util.GetEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+	}
+}
+
+// Dispatcher is the Notifier that looks up each user's preferences and
+// sends through every channel that reaches them.
+type Dispatcher struct {
+	users         repositories.UserRepository
+	notifications repositories.NotificationRepository
+	channels      []Channel
+}
+
+// New builds a Dispatcher with the in-app and webhook channels, and email
+// when SMTP is configured.
+func New(cfg *Config, users repositories.UserRepository, notifications repositories.NotificationRepository) *Dispatcher {
+	channels := []Channel{NewInAppChannel(notifications)}
+	if cfg.SMTPHost != "" {
+		channels = append(channels, NewEmailChannel(cfg))
+	}
+	channels = append(channels, NewWebhookChannel(cfg.WebhookSecret, cfg.WebhookTimeout))
+	return NewDispatcher(users, notifications, channels...)
+}
+
+func NewDispatcher(users repositories.UserRepository, notifications repositories.NotificationRepository, channels ...Channel) *Dispatcher {
+	return &Dispatcher{users: users, notifications: notifications, channels: channels}
+}
+
+// Notify tries every channel even when one fails, and returns the failures
+// joined together.
+func (d *Dispatcher) Notify(ctx context.Context, notification *models.Notification) error {
+	user, err := d.users.GetUserByID(ctx, uint(notification.UserId))
+	if err != nil {
+		return err
+	}
+	preference, err := d.notifications.GetPreference(ctx, notification.UserId)
+	if err != nil {
+		return err
+	}
+
+	to := Recipient{UserId: user.Id, Name: user.Name, WebhookUrl: preference.WebhookUrl}
+	if preference.EmailEnabled {
+		to.Email = user.Email
+	}
+
+	var errs []error
+	for _, channel := range d.channels {
+		if err := channel.Send(ctx, to, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type CategoryRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() CategoryRepository
+	CreateCategory(ctx context.Context, category *models.Category) error
+	GetCategoryByID(ctx context.Context, id int64) (*models.Category, error)
+	GetCategoryBySlug(ctx context.Context, slug string) (*models.Category, error)
+	GetCategoriesByIDs(ctx context.Context, ids []int64) ([]models.Category, error)
+	ListCategories(ctx context.Context) ([]models.Category, error)
+	UpdateCategory(ctx context.Context, category *models.Category) error
+	// DeleteCategory removes a leaf category and unlinks its products.
+	DeleteCategory(ctx context.Context, id int64) error
+	HasChildren(ctx context.Context, id int64) (bool, error)
+	// CategoryTree returns the root categories with their subtrees and
+	// product counts.
+	CategoryTree(ctx context.Context) ([]*models.CategoryNode, error)
+}
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepository(db *gorm.DB) CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+func (r *categoryRepository) UsePrimary() CategoryRepository {
+	return &categoryRepository{db: database.Primary(r.db)}
+}
+
+func (r *categoryRepository) CreateCategory(ctx context.Context, category *models.Category) error {
+	return r.db.WithContext(ctx).Create(category).Error
+}
+
+func (r *categoryRepository) GetCategoryByID(ctx context.Context, id int64) (*models.Category, error) {
+	var category models.Category
+	if err := r.db.WithContext(ctx).First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *categoryRepository) GetCategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	var category models.Category
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *categoryRepository) GetCategoriesByIDs(ctx context.Context, ids []int64) ([]models.Category, error) {
+	var categories []models.Category
+	if len(ids) == 0 {
+		return categories, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&categories).Error
+	return categories, err
+}
+
+// ListCategories returns every category ordered for display. The taxonomy is
+// small enough that callers walk it in memory.
+func (r *categoryRepository) ListCategories(ctx context.Context) ([]models.Category, error) {
+	var categories []models.Category
+	err := r.db.WithContext(ctx).Order("position, name").Find(&categories).Error
+	return categories, err
+}
+
+func (r *categoryRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	return r.db.WithContext(ctx).Model(category).
+		Select("parent_id", "name", "slug", "position").
+		Updates(category).Error
+}
+
+func (r *categoryRepository) DeleteCategory(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("category_id = ?", id).Delete(&models.ProductCategory{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Category{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *categoryRepository) HasChildren(ctx context.Context, id int64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Category{}).Where("parent_id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *categoryRepository) CategoryTree(ctx context.Context) ([]*models.CategoryNode, error) {
+	categories, err := r.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A product linked to several categories of one subtree must only be
+	// counted once there, so the counts are built from the links themselves.
+	var links []models.ProductCategory
+	err = r.db.WithContext(ctx).Model(&models.ProductCategory{}).
+		Joins("join products on products.id = product_categories.product_id").
+		Where("products.deleted_at IS NULL AND products.archived = ?", false).
+		Find(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[int64]*models.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.Id] = &models.CategoryNode{Category: category, Children: []*models.CategoryNode{}}
+	}
+
+	productsByNode := map[int64]map[int64]struct{}{}
+	for _, link := range links {
+		// Walk up to the root so every ancestor sees the product. The depth
+		// bound only matters if a cycle slipped into the data.
+		for id, depth := link.CategoryId, 0; depth < len(nodes); depth++ {
+			node, ok := nodes[id]
+			if !ok {
+				break
+			}
+			if productsByNode[id] == nil {
+				productsByNode[id] = map[int64]struct{}{}
+			}
+			productsByNode[id][link.ProductId] = struct{}{}
+			if node.ParentId == nil {
+				break
+			}
+			id = *node.ParentId
+		}
+	}
+
+	roots := []*models.CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.Id]
+		node.ProductCount = int64(len(productsByNode[category.Id]))
+		if parent, ok := nodes[derefID(category.ParentId)]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots, nil
+}
+
+// DescendantIDs returns id and the IDs of every category below it in
+// categories.
+func DescendantIDs(categories []models.Category, id int64) []int64 {
+	children := map[int64][]int64{}
+	for _, category := range categories {
+		if category.ParentId != nil {
+			children[*category.ParentId] = append(children[*category.ParentId], category.Id)
+		}
+	}
+
+	ids := []int64{id}
+	seen := map[int64]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+func derefID(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrImageOrderMismatch means a reorder request didn't list exactly the
+// product's images.
+var ErrImageOrderMismatch = errors.New("image order must list every image of the product exactly once")
+
+type ImageRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() ImageRepository
+	// CreateImage appends the image after the product's other images. The
+	// first image of a product becomes its primary image.
+	CreateImage(ctx context.Context, image *models.ProductImage) error
+	GetImage(ctx context.Context, productId, id int64) (*models.ProductImage, error)
+	// DeleteImage removes the image and, if it was the primary image,
+	// promotes the next one in order.
+	DeleteImage(ctx context.Context, image *models.ProductImage) error
+	SetPrimaryImage(ctx context.Context, productId, id int64) error
+	ReorderImages(ctx context.Context, productId int64, ids []int64) error
+}
+
+type imageRepository struct {
+	db *gorm.DB
+}
+
+func NewImageRepository(db *gorm.DB) ImageRepository {
+	return &imageRepository{db: db}
+}
+
+func (r *imageRepository) UsePrimary() ImageRepository {
+	return &imageRepository{db: database.Primary(r.db)}
+}
+
+func (r *imageRepository) CreateImage(ctx context.Context, image *models.ProductImage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing struct {
+			Count       int64
+			MaxPosition int
+		}
+		err := tx.Model(&models.ProductImage{}).
+			Select("COUNT(*) AS count, COALESCE(MAX(position), -1) AS max_position").
+			Where("product_id = ?", image.ProductId).
+			Scan(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		image.Position = existing.MaxPosition + 1
+		image.IsPrimary = existing.Count == 0
+		return tx.Create(image).Error
+	})
+}
+
+func (r *imageRepository) GetImage(ctx context.Context, productId, id int64) (*models.ProductImage, error) {
+	var image models.ProductImage
+	err := r.db.WithContext(ctx).Where("id = ? AND product_id = ?", id, productId).First(&image).Error
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func (r *imageRepository) DeleteImage(ctx context.Context, image *models.ProductImage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.ProductImage{}, image.Id).Error; err != nil {
+			return err
+		}
+		if !image.IsPrimary {
+			return nil
+		}
+
+		var next models.ProductImage
+		err := tx.Where("product_id = ?", image.ProductId).Order("position, id").First(&next).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&next).Update("is_primary", true).Error
+	})
+}
+
+func (r *imageRepository) SetPrimaryImage(ctx context.Context, productId, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProductImage{}).
+			Where("product_id = ? AND id = ?", productId, id).
+			Update("is_primary", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.ProductImage{}).
+			Where("product_id = ? AND id <> ?", productId, id).
+			Update("is_primary", false).Error
+	})
+}
+
+func (r *imageRepository) ReorderImages(ctx context.Context, productId int64, ids []int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.ProductImage{}).
+			Where("product_id = ? AND id IN ?", productId, ids).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		var total int64
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", productId).Count(&total).Error; err != nil {
+			return err
+		}
+		if count != int64(len(ids)) || total != count {
+			return ErrImageOrderMismatch
+		}
+
+		for position, id := range ids {
+			if err := tx.Model(&models.ProductImage{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type ImportJobRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() ImportJobRepository
+	CreateImportJob(ctx context.Context, job *models.ImportJob) error
+	GetImportJob(ctx context.Context, merchantId, id int64) (*models.ImportJob, error)
+	// SaveImportJob writes the job's progress.
+	SaveImportJob(ctx context.Context, job *models.ImportJob) error
+}
+
+type importJobRepository struct {
+	db *gorm.DB
+}
+
+func NewImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &importJobRepository{db: db}
+}
+
+func (r *importJobRepository) UsePrimary() ImportJobRepository {
+	return &importJobRepository{db: database.Primary(r.db)}
+}
+
+func (r *importJobRepository) CreateImportJob(ctx context.Context, job *models.ImportJob) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *importJobRepository) GetImportJob(ctx context.Context, merchantId, id int64) (*models.ImportJob, error) {
+	var job models.ImportJob
+	err := r.db.WithContext(ctx).Where("id = ? AND merchant_id = ?", id, merchantId).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *importJobRepository) SaveImportJob(ctx context.Context, job *models.ImportJob) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
@@ -0,0 +1,285 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reconcileBatchSize is how many stock counts ReconcileStock checks at a time.
+const reconcileBatchSize = 500
+
+type InventoryRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() InventoryRepository
+	// AdjustStock applies movement.Delta to the stock of its product or
+	// variant and appends it to the ledger, filling in the balance. A
+	// decrement that would leave negative stock fails with
+	// ErrInsufficientStock.
+	AdjustStock(ctx context.Context, movement *models.InventoryMovement) error
+	// ListMovements returns a page of the product's ledger, newest first.
+	ListMovements(ctx context.Context, productId int64, filter models.InventoryMovementQuery) ([]models.InventoryMovement, int64, error)
+	// ListMovementsAfter returns up to limit movements of any product with
+	// IDs above afterId, oldest first.
+	ListMovementsAfter(ctx context.Context, afterId int64, limit int) ([]models.InventoryMovement, error)
+	// LastMovementId is the ID of the newest movement, or 0.
+	LastMovementId(ctx context.Context) (int64, error)
+	// SoldTransactions returns which of the transactions have a sale in the
+	// ledger.
+	SoldTransactions(ctx context.Context, transactionIds []int64) (map[int64]bool, error)
+	// ReconcileStock makes every stock count equal to the sum of its ledger.
+	// Stock without movements, from before the ledger existed, gets an
+	// opening adjustment instead. It returns how many counts it opened and
+	// corrected.
+	ReconcileStock(ctx context.Context) (opened, corrected int64, err error)
+}
+
+type inventoryRepository struct {
+	db *gorm.DB
+}
+
+func NewInventoryRepository(db *gorm.DB) InventoryRepository {
+	return &inventoryRepository{db: db}
+}
+
+func (r *inventoryRepository) UsePrimary() InventoryRepository {
+	return &inventoryRepository{db: database.Primary(r.db)}
+}
+
+func (r *inventoryRepository) AdjustStock(ctx context.Context, movement *models.InventoryMovement) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return applyMovement(tx, movement)
+	})
+}
+
+func (r *inventoryRepository) ListMovements(ctx context.Context, productId int64, filter models.InventoryMovementQuery) ([]models.InventoryMovement, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.InventoryMovement{}).Where("product_id = ?", productId)
+	if filter.VariantId > 0 {
+		query = query.Where("variant_id = ?", filter.VariantId)
+	}
+	if filter.Reason != "" {
+		query = query.Where("reason = ?", filter.Reason)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var movements []models.InventoryMovement
+	err := query.Order("id DESC").
+		Offset((filter.Page - 1) * filter.Limit).
+		Limit(filter.Limit).
+		Find(&movements).Error
+	return movements, total, err
+}
+
+func (r *inventoryRepository) ListMovementsAfter(ctx context.Context, afterId int64, limit int) ([]models.InventoryMovement, error) {
+	var movements []models.InventoryMovement
+	err := r.db.WithContext(ctx).Where("id > ?", afterId).Order("id").Limit(limit).Find(&movements).Error
+	return movements, err
+}
+
+func (r *inventoryRepository) LastMovementId(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.db.WithContext(ctx).Model(&models.InventoryMovement{}).Select("COALESCE(MAX(id), 0)").Row().Scan(&id)
+	return id, err
+}
+
+func (r *inventoryRepository) SoldTransactions(ctx context.Context, transactionIds []int64) (map[int64]bool, error) {
+	sold := make(map[int64]bool)
+	if len(transactionIds) == 0 {
+		return sold, nil
+	}
+
+	var ids []int64
+	err := r.db.WithContext(ctx).Model(&models.InventoryMovement{}).
+		Where("reason = ? AND transaction_id IN ?", models.MovementSale, transactionIds).
+		Distinct().Pluck("transaction_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		sold[id] = true
+	}
+	return sold, nil
+}
+
+// stockCount is a product's or variant's quantity next to its ledger.
+type stockCount struct {
+	Id        int64
+	ProductId int64
+	Quantity  int64
+	Ledger    int64
+	Movements int64
+}
+
+func (r *inventoryRepository) ReconcileStock(ctx context.Context) (opened, corrected int64, err error) {
+	// Product-level stock only has movements without a variant.
+	products := func(db *gorm.DB) *gorm.DB {
+		return db.Table("products").
+			Select("products.id, products.id as product_id, products.quantity, COALESCE(SUM(m.delta), 0) as ledger, COUNT(m.id) as movements").
+			Joins("left join inventory_movements as m on m.product_id = products.id and m.variant_id is null").
+			Group("products.id, products.quantity")
+	}
+	variants := func(db *gorm.DB) *gorm.DB {
+		return db.Table("product_variants").
+			Select("product_variants.id, product_variants.product_id, product_variants.quantity, COALESCE(SUM(m.delta), 0) as ledger, COUNT(m.id) as movements").
+			Joins("left join inventory_movements as m on m.variant_id = product_variants.id").
+			Group("product_variants.id, product_variants.product_id, product_variants.quantity")
+	}
+
+	for _, table := range []struct {
+		name   string
+		counts func(*gorm.DB) *gorm.DB
+	}{{"products", products}, {"product_variants", variants}} {
+		var afterId int64
+		for {
+			var counts []stockCount
+			err := table.counts(r.db.WithContext(ctx)).
+				Where(table.name+".id > ?", afterId).
+				Order(table.name + ".id").
+				Limit(reconcileBatchSize).
+				Scan(&counts).Error
+			if err != nil {
+				return opened, corrected, err
+			}
+
+			for _, count := range counts {
+				var variantId *int64
+				if table.name == "product_variants" {
+					variantId = &count.Id
+				}
+				switch {
+				case count.Movements == 0 && count.Quantity != 0:
+					if err := r.openStock(ctx, table.name, count, variantId); err != nil {
+						return opened, corrected, err
+					}
+					opened++
+				case count.Movements > 0 && count.Ledger != count.Quantity:
+					fixed, err := r.correctStock(ctx, table.name, count)
+					if err != nil {
+						return opened, corrected, err
+					}
+					if fixed {
+						slog.WarnContext(ctx, "Corrected stock that drifted from the ledger",
+							"product_id", count.ProductId, "variant_id", variantId, "quantity", count.Quantity, "ledger", count.Ledger)
+						corrected++
+					}
+				}
+			}
+
+			if len(counts) < reconcileBatchSize {
+				break
+			}
+			afterId = counts[len(counts)-1].Id
+		}
+	}
+	return opened, corrected, nil
+}
+
+// openStock records the quantity of stock that has no history as an opening
+// adjustment, unless a movement appeared in the meantime.
+func (r *inventoryRepository) openStock(ctx context.Context, table string, count stockCount, variantId *int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.InventoryMovement{})
+		if variantId != nil {
+			query = query.Where("variant_id = ?", *variantId)
+		} else {
+			query = query.Where("product_id = ? AND variant_id IS NULL", count.ProductId)
+		}
+		var movements int64
+		if err := query.Count(&movements).Error; err != nil || movements > 0 {
+			return err
+		}
+
+		var quantity int64
+		if err := tx.Table(table).Select("quantity").Where("id = ?", count.Id).Row().Scan(&quantity); err != nil {
+			return err
+		}
+		return appendMovement(tx, &models.InventoryMovement{
+			ProductId: count.ProductId,
+			VariantId: variantId,
+			Reason:    models.MovementAdjustment,
+			Delta:     quantity,
+			Balance:   quantity,
+			Note:      "Opening balance",
+		})
+	})
+}
+
+// correctStock sets the quantity to the ledger's sum, unless the quantity
+// changed since it was read; the next run then checks it again.
+func (r *inventoryRepository) correctStock(ctx context.Context, table string, count stockCount) (bool, error) {
+	result := r.db.WithContext(ctx).Table(table).
+		Where("id = ? AND quantity = ?", count.Id, count.Quantity).
+		Updates(map[string]interface{}{
+			"quantity":   count.Ledger,
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// applyMovement changes the stock the movement refers to by its delta and
+// appends it to the ledger. It must run inside a database transaction so
+// neither happens without the other.
+func applyMovement(tx *gorm.DB, movement *models.InventoryMovement) error {
+	result := stockOf(tx, movement).
+		Where("quantity + ? >= 0", movement.Delta).
+		Updates(map[string]interface{}{
+			"quantity":   gorm.Expr("quantity + ?", movement.Delta),
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if movement.Delta < 0 {
+			return ErrInsufficientStock
+		}
+		return gorm.ErrRecordNotFound
+	}
+
+	if err := stockOf(tx, movement).Select("quantity").Row().Scan(&movement.Balance); err != nil {
+		return err
+	}
+	return appendMovement(tx, movement)
+}
+
+// appendMovement adds a movement whose change the caller already made to the
+// stock, with its balance filled in.
+func appendMovement(tx *gorm.DB, movement *models.InventoryMovement) error {
+	return tx.Create(movement).Error
+}
+
+// stockOf selects the row holding the stock a movement changes. Deleted
+// products still take refunds and releases.
+func stockOf(tx *gorm.DB, movement *models.InventoryMovement) *gorm.DB {
+	if movement.VariantId != nil {
+		return tx.Model(&models.ProductVariant{}).Where("id = ?", *movement.VariantId)
+	}
+	return tx.Unscoped().Model(&models.Product{}).Where("id = ?", movement.ProductId)
+}
+
+// recordQuantityChange appends an adjustment for a quantity that was set
+// directly, such as by a product or variant update, from previous to
+// current.
+func recordQuantityChange(tx *gorm.DB, productId int64, variantId *int64, previous, current int64, note string) error {
+	if previous == current {
+		return nil
+	}
+	return appendMovement(tx, &models.InventoryMovement{
+		ProductId: productId,
+		VariantId: variantId,
+		Reason:    models.MovementAdjustment,
+		Delta:     current - previous,
+		Balance:   current,
+		Note:      note,
+	})
+}
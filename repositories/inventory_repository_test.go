@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/models"
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// ledger returns the stock of the product, or of the variant when it is
+// set, with the number and sum of its movements.
+func ledger(t *testing.T, db *gorm.DB, productId int64, variantId *int64) (quantity, movements, sum int64) {
+	t.Helper()
+	stock := db.Model(&models.Product{}).Where("id = ?", productId)
+	query := db.Model(&models.InventoryMovement{}).Where("product_id = ? AND variant_id IS NULL", productId)
+	if variantId != nil {
+		stock = db.Model(&models.ProductVariant{}).Where("id = ?", *variantId)
+		query = db.Model(&models.InventoryMovement{}).Where("variant_id = ?", *variantId)
+	}
+	if err := stock.Select("quantity").Row().Scan(&quantity); err != nil {
+		t.Fatalf("read quantity: %v", err)
+	}
+	if err := query.Select("COUNT(*), COALESCE(SUM(delta), 0)").Row().Scan(&movements, &sum); err != nil {
+		t.Fatalf("read ledger: %v", err)
+	}
+	return quantity, movements, sum
+}
+
+func TestAdjustStock(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewInventoryRepository(db)
+	product := createProducts(t, NewProductRepository(db), 10)[0]
+	variant := models.ProductVariant{ProductId: product.Id, Sku: "A-S", Quantity: 1}
+	if err := db.Create(&variant).Error; err != nil {
+		t.Fatalf("create variant: %v", err)
+	}
+	_, before, _ := ledger(t, db, product.Id, nil)
+
+	tests := []struct {
+		name      string
+		variantId *int64
+		delta     int64
+		wantErr   error
+		wantStock int64
+	}{
+		{"below zero", nil, -2, ErrInsufficientStock, 1},
+		{"to zero", nil, -1, nil, 0},
+		{"restock", nil, 5, nil, 5},
+		{"variant below zero", &variant.Id, -2, ErrInsufficientStock, 1},
+		{"variant to zero", &variant.Id, -1, nil, 0},
+	}
+	movements := map[bool]int64{false: before, true: 0}
+	for _, tt := range tests {
+		movement := &models.InventoryMovement{ProductId: product.Id, VariantId: tt.variantId, Reason: models.MovementAdjustment, Delta: tt.delta}
+		err := repo.AdjustStock(ctx, movement)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: AdjustStock = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil {
+			movements[tt.variantId != nil]++
+			if movement.Balance != tt.wantStock {
+				t.Errorf("%s: balance = %d, want %d", tt.name, movement.Balance, tt.wantStock)
+			}
+		}
+
+		quantity, count, _ := ledger(t, db, product.Id, tt.variantId)
+		if quantity != tt.wantStock || count != movements[tt.variantId != nil] {
+			t.Errorf("%s: stock %d with %d movements, want %d with %d", tt.name, quantity, count, tt.wantStock, movements[tt.variantId != nil])
+		}
+	}
+
+	err := repo.AdjustStock(ctx, &models.InventoryMovement{ProductId: product.Id + 1, Reason: models.MovementRestock, Delta: 1})
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("AdjustStock of a missing product = %v, want ErrRecordNotFound", err)
+	}
+	var count int64
+	db.Model(&models.InventoryMovement{}).Where("product_id = ?", product.Id+1).Count(&count)
+	if count != 0 {
+		t.Errorf("a missing product got %d movements", count)
+	}
+}
+
+func TestReconcileStock(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewInventoryRepository(db)
+	products := createProducts(t, NewProductRepository(db), 10, 20)
+	drifted, kept := products[0], products[1]
+	if err := repo.AdjustStock(ctx, &models.InventoryMovement{ProductId: drifted.Id, Reason: models.MovementRestock, Delta: 4}); err != nil {
+		t.Fatalf("AdjustStock: %v", err)
+	}
+	// A write that bypassed the ledger, and stock from before it existed.
+	db.Model(&models.Product{}).Where("id = ?", drifted.Id).Update("quantity", 9)
+	legacy := models.Product{Name: "Old Shirt", Price: 30, Quantity: 6, MerchantId: 1}
+	db.Create(&legacy)
+	db.Where("product_id = ?", legacy.Id).Delete(&models.InventoryMovement{})
+
+	opened, corrected, err := repo.ReconcileStock(ctx)
+	if err != nil || opened != 1 || corrected != 1 {
+		t.Fatalf("ReconcileStock = %d opened, %d corrected, %v; want 1 and 1", opened, corrected, err)
+	}
+	for _, tt := range []struct {
+		name string
+		id   int64
+		want int64
+	}{{"drifted", drifted.Id, 5}, {"kept", kept.Id, 1}, {"legacy", legacy.Id, 6}} {
+		if quantity, _, sum := ledger(t, db, tt.id, nil); quantity != tt.want || sum != tt.want {
+			t.Errorf("%s product: stock %d with ledger %d, want %d", tt.name, quantity, sum, tt.want)
+		}
+	}
+
+	if opened, corrected, err := repo.ReconcileStock(ctx); err != nil || opened != 0 || corrected != 0 {
+		t.Errorf("second ReconcileStock = %d opened, %d corrected, %v; want nothing to do", opened, corrected, err)
+	}
+}
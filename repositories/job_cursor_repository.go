@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type JobCursorRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() JobCursorRepository
+	// GetJobCursor returns the cursor's position, starting it at start the
+	// first time.
+	GetJobCursor(ctx context.Context, name string, start int64) (int64, error)
+	// AdvanceJobCursor moves the cursor from one position to another. It
+	// returns false when another instance moved it first, which then owns
+	// the entries in between.
+	AdvanceJobCursor(ctx context.Context, name string, from, to int64) (bool, error)
+}
+
+type jobCursorRepository struct {
+	db *gorm.DB
+}
+
+func NewJobCursorRepository(db *gorm.DB) JobCursorRepository {
+	return &jobCursorRepository{db: db}
+}
+
+func (r *jobCursorRepository) UsePrimary() JobCursorRepository {
+	return &jobCursorRepository{db: database.Primary(r.db)}
+}
+
+func (r *jobCursorRepository) GetJobCursor(ctx context.Context, name string, start int64) (int64, error) {
+	db := r.db.WithContext(ctx)
+	cursor := models.JobCursor{Name: name, Position: start, UpdatedAt: time.Now()}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursor).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Where("name = ?", name).First(&cursor).Error; err != nil {
+		return 0, err
+	}
+	return cursor.Position, nil
+}
+
+func (r *jobCursorRepository) AdvanceJobCursor(ctx context.Context, name string, from, to int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.JobCursor{}).
+		Where("name = ? AND position = ?", name, from).
+		Updates(map[string]interface{}{"position": to, "updated_at": time.Now()})
+	return result.RowsAffected > 0, result.Error
+}
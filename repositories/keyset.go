@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/models"
+	"errors"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCursor means a cursor doesn't fit the sort order of the listing
+// it was used on.
+var ErrInvalidCursor = errors.New("cursor does not match the listing")
+
+// sortKey is one ORDER BY term of a keyset-paginated listing. The last key
+// of a listing is always its unique ID column.
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// paginate orders query by keys and limits it to one page: the rows after
+// cursor when there is one, otherwise the rows at offset. It fetches one row
+// more than limit so pageOf can tell whether the listing continues.
+func paginate(query *gorm.DB, keys []sortKey, cursor *models.Cursor, offset, limit int) (*gorm.DB, error) {
+	backward := false
+	if cursor != nil {
+		if len(cursor.Values) != len(keys)-1 {
+			return nil, ErrInvalidCursor
+		}
+		backward = cursor.Backward
+		values := make([]interface{}, 0, len(keys))
+		for _, value := range cursor.Values {
+			values = append(values, value)
+		}
+		values = append(values, cursor.Id)
+		query = query.Where(keysetCondition(keys, values, backward))
+		offset = 0
+	}
+
+	for _, key := range keys {
+		// Paging backward reads the rows before the cursor nearest first;
+		// pageOf restores the listing order.
+		if key.desc != backward {
+			query = query.Order(key.column + " DESC")
+		} else {
+			query = query.Order(key.column + " ASC")
+		}
+	}
+	return query.Offset(offset).Limit(limit + 1), nil
+}
+
+// keysetCondition matches the rows that sort after values, or before them
+// when backward is set.
+func keysetCondition(keys []sortKey, values []interface{}, backward bool) clause.Expr {
+	var alternatives []string
+	var vars []interface{}
+	for i, key := range keys {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, keys[j].column+" = ?")
+			vars = append(vars, values[j])
+		}
+		op := " > ?"
+		if key.desc != backward {
+			op = " < ?"
+		}
+		terms = append(terms, key.column+op)
+		vars = append(vars, values[i])
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(alternatives, " OR ") + ")", Vars: vars}
+}
+
+// pageOf builds the page from rows fetched by paginate, using position to
+// turn its first and last rows into cursors.
+func pageOf[T any](rows []T, cursor *models.Cursor, offset, limit int, position func(T) models.Cursor) *models.Page[T] {
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	backward := cursor != nil && cursor.Backward
+	if backward {
+		slices.Reverse(rows)
+	}
+
+	page := &models.Page[T]{Items: rows}
+	if len(rows) == 0 {
+		return page
+	}
+	hasNext, hasPrev := more, cursor != nil || offset > 0
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		next := position(rows[len(rows)-1])
+		page.Next = &next
+	}
+	if hasPrev {
+		prev := position(rows[0])
+		prev.Backward = true
+		page.Prev = &prev
+	}
+	return page
+}
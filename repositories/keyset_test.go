@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// createProducts adds products with the prices, in order, and returns them.
+func createProducts(t *testing.T, repo ProductRepository, prices ...float64) []models.Product {
+	t.Helper()
+	products := make([]models.Product, len(prices))
+	for i, price := range prices {
+		products[i] = models.Product{Name: fmt.Sprintf("Shirt %d", i), Price: price, Quantity: 1, MerchantId: 1}
+		if err := repo.CreateProduct(context.Background(), &products[i]); err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+	}
+	return products
+}
+
+func listPage(t *testing.T, repo ProductRepository, filter models.ProductQuery) ([]int64, *models.Page[models.ProductDetail]) {
+	t.Helper()
+	page, err := repo.ListProducts(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("ListProducts(%+v): %v", filter, err)
+	}
+	ids := make([]int64, 0, len(page.Items))
+	for _, product := range page.Items {
+		ids = append(ids, product.Id)
+	}
+	return ids, page
+}
+
+// walk follows the Next cursors from filter, or the Prev cursors when
+// backward is set, and returns the IDs of every page in listing order.
+func walk(t *testing.T, repo ProductRepository, filter models.ProductQuery, backward bool) []int64 {
+	t.Helper()
+	var all []int64
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("listing does not end")
+		}
+		ids, page := listPage(t, repo, filter)
+		cursor := page.Next
+		if backward {
+			all = append(ids, all...)
+			cursor = page.Prev
+		} else {
+			all = append(all, ids...)
+		}
+		if cursor == nil {
+			return all
+		}
+		filter.Position, filter.Page = cursor, 1
+	}
+}
+
+func TestKeysetPagination(t *testing.T) {
+	repo := NewProductRepository(newTestDB(t))
+	// Repeated prices make the ID decide the order within each price.
+	products := createProducts(t, repo, 30, 10, 20, 10, 30, 20, 10, 20, 30, 10, 20)
+	byPrice := slices.Clone(products)
+	slices.SortStableFunc(byPrice, func(a, b models.Product) int {
+		if a.Price != b.Price {
+			return int(a.Price - b.Price)
+		}
+		return int(b.Id - a.Id)
+	})
+	var want []int64
+	for _, product := range byPrice {
+		want = append(want, product.Id)
+	}
+
+	filter := models.ProductQuery{Page: 1, Limit: 3, Sort: models.ProductSortPriceAsc}
+	if got := walk(t, repo, filter, false); !slices.Equal(got, want) {
+		t.Errorf("forward = %v, want %v", got, want)
+	}
+
+	// Back from the last page, which holds the last two products.
+	filter.Page = 4
+	last, page := listPage(t, repo, filter)
+	if !slices.Equal(last, want[9:]) || page.Next != nil {
+		t.Fatalf("last page = %v (next %v), want %v", last, page.Next, want[9:])
+	}
+	filter.Page, filter.Position = 1, page.Prev
+	if got := append(walk(t, repo, filter, true), last...); !slices.Equal(got, want) {
+		t.Errorf("backward = %v, want %v", got, want)
+	}
+
+	// A cursor taken from an offset page continues where the offset left
+	// off, and leads back to the page before it.
+	filter = models.ProductQuery{Page: 2, Limit: 3, Sort: models.ProductSortPriceAsc}
+	second, page := listPage(t, repo, filter)
+	if !slices.Equal(second, want[3:6]) {
+		t.Fatalf("page 2 = %v, want %v", second, want[3:6])
+	}
+	next, prev := page.Next, page.Prev
+	filter.Page, filter.Position = 1, next
+	if got, _ := listPage(t, repo, filter); !slices.Equal(got, want[6:9]) {
+		t.Errorf("page after page 2 = %v, want %v", got, want[6:9])
+	}
+	filter.Position = prev
+	if got, page := listPage(t, repo, filter); !slices.Equal(got, want[:3]) || page.Prev != nil {
+		t.Errorf("page before page 2 = %v (prev %v), want %v", got, page.Prev, want[:3])
+	}
+}
+
+func TestKeysetPaginationWithInserts(t *testing.T) {
+	repo := NewProductRepository(newTestDB(t))
+	products := createProducts(t, repo, 10, 20, 30, 40, 50, 60)
+
+	filter := models.ProductQuery{Page: 1, Limit: 2}
+	first, page := listPage(t, repo, filter)
+
+	// Newer products sort before the pages already read, so they move
+	// neither the remaining pages nor the ones read by going back.
+	newer := createProducts(t, repo, 70, 80, 90)
+	filter.Position = page.Next
+	rest := walk(t, repo, filter, false)
+
+	var want []int64
+	for i := len(products) - 1; i >= 0; i-- {
+		want = append(want, products[i].Id)
+	}
+	if got := append(first, rest...); !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v with no gaps or repeats", got, want)
+	}
+
+	// By price the new products sort after the current page, so they show
+	// up on the pages still to come.
+	filter = models.ProductQuery{Page: 1, Limit: 4, Sort: models.ProductSortPriceAsc}
+	first, page = listPage(t, repo, filter)
+	added := createProducts(t, repo, 15, 55)
+	filter.Position = page.Next
+	rest = walk(t, repo, filter, false)
+
+	want = []int64{products[0].Id, products[1].Id, products[2].Id, products[3].Id,
+		products[4].Id, added[1].Id, products[5].Id, newer[0].Id, newer[1].Id, newer[2].Id}
+	if got := append(first, rest...); !slices.Equal(got, want) {
+		t.Errorf("pages by price = %v, want %v", got, want)
+	}
+}
+
+func TestKeysetPaginationOverRankedHits(t *testing.T) {
+	repo := NewProductRepository(newTestDB(t))
+	products := createProducts(t, repo, 10, 10, 10, 10, 10, 10, 10)
+	// The search ranks products in an order unrelated to their IDs.
+	var hits []int64
+	for _, i := range []int{3, 0, 6, 1, 5, 2, 4} {
+		hits = append(hits, products[i].Id)
+	}
+
+	filter := models.ProductQuery{Page: 1, Limit: 3, SearchIds: hits}
+	if got := walk(t, repo, filter, false); !slices.Equal(got, hits) {
+		t.Errorf("forward = %v, want %v", got, hits)
+	}
+
+	filter.Page = 3
+	last, page := listPage(t, repo, filter)
+	filter.Page, filter.Position = 1, page.Prev
+	if got := append(walk(t, repo, filter, true), last...); !slices.Equal(got, hits) {
+		t.Errorf("backward = %v, want %v", got, hits)
+	}
+
+	// A hit that stops matching after the first page leaves the rest of the
+	// ranking in place.
+	filter = models.ProductQuery{Page: 1, Limit: 3, SearchIds: hits}
+	first, page := listPage(t, repo, filter)
+	if err := repo.DeleteProduct(context.Background(), &products[5]); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	filter.Position = page.Next
+	want := slices.Delete(slices.Clone(hits), 4, 5)
+	if got := append(first, walk(t, repo, filter, false)...); !slices.Equal(got, want) {
+		t.Errorf("pages after a deletion = %v, want %v", got, want)
+	}
+}
+
+func TestPaginateRejectsForeignCursor(t *testing.T) {
+	repo := NewProductRepository(newTestDB(t))
+	// A newest-first cursor has no price to continue a price listing from.
+	filter := models.ProductQuery{Page: 1, Limit: 3, Sort: models.ProductSortPriceAsc, Position: &models.Cursor{Id: 1}}
+	if _, err := repo.ListProducts(context.Background(), filter); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ListProducts = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() NotificationRepository
+	CreateNotification(ctx context.Context, notification *models.Notification) error
+	// ListNotifications returns a page of the user's inbox, newest first,
+	// with the total and unread counts.
+	ListNotifications(ctx context.Context, userId int64, filter models.NotificationQuery) ([]models.Notification, int64, int64, error)
+	// MarkRead marks one notification, or every one with id 0, as read.
+	// An unknown notification is gorm.ErrRecordNotFound.
+	MarkRead(ctx context.Context, userId, id int64) error
+	// GetPreference returns the user's preference, or the default one.
+	GetPreference(ctx context.Context, userId int64) (*models.NotificationPreference, error)
+	SavePreference(ctx context.Context, preference *models.NotificationPreference) error
+
+	// Subscribe saves the customer's back-in-stock subscription, arming it
+	// again if it already fired.
+	Subscribe(ctx context.Context, subscription *models.StockSubscription) error
+	Unsubscribe(ctx context.Context, customerId, productId, variantId int64) error
+	// ClaimSubscribers marks the armed subscriptions to the product, or to
+	// its variant when variantId isn't 0, as notified and returns their
+	// customers.
+	ClaimSubscribers(ctx context.Context, productId, variantId int64) ([]int64, error)
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) UsePrimary() NotificationRepository {
+	return &notificationRepository{db: database.Primary(r.db)}
+}
+
+func (r *notificationRepository) CreateNotification(ctx context.Context, notification *models.Notification) error {
+	return r.db.WithContext(ctx).Create(notification).Error
+}
+
+func (r *notificationRepository) ListNotifications(ctx context.Context, userId int64, filter models.NotificationQuery) ([]models.Notification, int64, int64, error) {
+	inbox := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&models.Notification{}).Where("user_id = ?", userId)
+	}
+
+	var unread int64
+	if err := inbox().Where("read_at IS NULL").Count(&unread).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	query := inbox()
+	if filter.Unread {
+		query = query.Where("read_at IS NULL")
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	var notifications []models.Notification
+	err := query.Order("id DESC").
+		Offset((filter.Page - 1) * filter.Limit).
+		Limit(filter.Limit).
+		Find(&notifications).Error
+	return notifications, total, unread, err
+}
+
+func (r *notificationRepository) MarkRead(ctx context.Context, userId, id int64) error {
+	query := r.db.WithContext(ctx).Model(&models.Notification{}).Where("user_id = ?", userId)
+	if id == 0 {
+		return query.Where("read_at IS NULL").Update("read_at", time.Now()).Error
+	}
+
+	result := query.Where("id = ?", id).Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *notificationRepository) GetPreference(ctx context.Context, userId int64) (*models.NotificationPreference, error) {
+	preference := models.NotificationPreference{UserId: userId, EmailEnabled: true}
+	err := r.db.WithContext(ctx).Where("user_id = ?", userId).First(&preference).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+func (r *notificationRepository) SavePreference(ctx context.Context, preference *models.NotificationPreference) error {
+	return r.db.WithContext(ctx).Save(preference).Error
+}
+
+func (r *notificationRepository) Subscribe(ctx context.Context, subscription *models.StockSubscription) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "variant_id"}, {Name: "customer_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"notified_at": nil, "updated_at": time.Now()}),
+	}).Create(subscription).Error
+}
+
+func (r *notificationRepository) Unsubscribe(ctx context.Context, customerId, productId, variantId int64) error {
+	result := r.db.WithContext(ctx).
+		Where("customer_id = ? AND product_id = ? AND variant_id = ?", customerId, productId, variantId).
+		Delete(&models.StockSubscription{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *notificationRepository) ClaimSubscribers(ctx context.Context, productId, variantId int64) ([]int64, error) {
+	var customerIds []int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		armed := func() *gorm.DB {
+			return tx.Model(&models.StockSubscription{}).
+				Where("product_id = ? AND variant_id = ? AND notified_at IS NULL", productId, variantId)
+		}
+		if err := armed().Pluck("customer_id", &customerIds).Error; err != nil {
+			return err
+		}
+		if len(customerIds) == 0 {
+			return nil
+		}
+		now := time.Now()
+		return armed().Where("customer_id IN ?", customerIds).
+			Updates(map[string]interface{}{"notified_at": now, "updated_at": now}).Error
+	})
+	return customerIds, err
+}
@@ -1,18 +1,73 @@
 package repositories
 
 import (
+	"backend-hanssen-hilman/database"
 	"backend-hanssen-hilman/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict means the product changed since the caller read it.
+var ErrVersionConflict = errors.New("product was modified concurrently")
+
+// maxSearchMatches bounds how many search hits that pass the other filters
+// are ranked and paged through.
+const maxSearchMatches = 1000
+
+// searchChunkSize is how many search hits are checked against the filters
+// per query.
+const searchChunkSize = 500
+
+// ErrInsufficientStock means a stock decrement would go below zero.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
 type ProductRepository interface {
-	CreateProduct(product *models.Product) error
-	GetProductByID(id int64) (*models.ProductDetail, error)
-	GetProductByMerchantID(id int64, page, limit int) ([]models.ProductDetail, int64, error)
-	UpdateProduct(product *models.Product) error
-	DeleteProduct(id uint) error
-	ListProducts(filter models.ProductRequest) ([]models.ProductDetail, int64, error)
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() ProductRepository
+	CreateProduct(ctx context.Context, product *models.Product) error
+	GetProductByID(ctx context.Context, id int64) (*models.ProductDetail, error)
+	// GetProductByMerchantID lists the merchant's products, newest first,
+	// from cursor if set and otherwise from the page number.
+	GetProductByMerchantID(ctx context.Context, id int64, cursor *models.Cursor, page, limit int) (*models.Page[models.ProductDetail], error)
+	UpdateProduct(ctx context.Context, product *models.Product) error
+	SetArchived(ctx context.Context, product *models.Product, archived bool) error
+	// DeleteProduct soft-deletes the product; RestoreProduct undoes it.
+	DeleteProduct(ctx context.Context, product *models.Product) error
+	RestoreProduct(ctx context.Context, merchantId, id int64) error
+	ListDeletedProducts(ctx context.Context, merchantId int64, page, limit int) ([]models.Product, int64, error)
+	// PurgeDeletedProducts permanently removes products deleted before the
+	// cutoff that no transaction refers to, along with the rows that belong
+	// to them. It returns how many it removed and the blob keys of their
+	// images, which the caller must delete.
+	PurgeDeletedProducts(ctx context.Context, before time.Time) (int64, []string, error)
+	// ListProducts returns the page of the catalog at filter.Position, or at
+	// filter.Page without a cursor.
+	ListProducts(ctx context.Context, filter models.ProductQuery) (*models.Page[models.ProductDetail], error)
+	// ProductFacets counts the products matching filter by price bucket,
+	// merchant and category. Search order and paging are ignored.
+	ProductFacets(ctx context.Context, filter models.ProductQuery) (*models.ProductFacets, error)
+	// SkuTaken reports whether a product of the merchant other than
+	// exceptId, deleted or not, uses sku.
+	SkuTaken(ctx context.Context, merchantId int64, sku string, exceptId int64) (bool, error)
+	// GetProductBySku finds the merchant's product with sku, including a
+	// soft-deleted one, with its categories.
+	GetProductBySku(ctx context.Context, merchantId int64, sku string) (*models.Product, error)
+	// ListMerchantProducts returns up to limit of the merchant's products
+	// with an ID above afterId, in ID order, with their categories.
+	ListMerchantProducts(ctx context.Context, merchantId, afterId int64, limit int) ([]models.Product, error)
+	// GetProductsByIDs loads the products that exist among ids, with their
+	// variants.
+	GetProductsByIDs(ctx context.Context, ids []int64) ([]models.Product, error)
+	// ListSearchable returns up to limit products with an ID above afterId,
+	// in ID order, with only the columns the search index needs.
+	ListSearchable(ctx context.Context, afterId int64, limit int) ([]models.Product, error)
 }
 
 type productRepository struct {
@@ -23,15 +78,28 @@ func NewProductRepository(db *gorm.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) CreateProduct(product *models.Product) error {
-	return r.db.Create(product).Error
+func (r *productRepository) UsePrimary() ProductRepository {
+	return &productRepository{db: database.Primary(r.db)}
+}
+
+// CreateProduct inserts the product and links it to product.Categories,
+// which must already exist. Its initial stock is recorded in the ledger.
+func (r *productRepository) CreateProduct(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Categories.*").Create(product).Error; err != nil {
+			return err
+		}
+		return recordQuantityChange(tx, product.Id, nil, 0, product.Quantity, "Initial stock")
+	})
 }
 
-func (r *productRepository) GetProductByID(id int64) (*models.ProductDetail, error) {
+func (r *productRepository) GetProductByID(ctx context.Context, id int64) (*models.ProductDetail, error) {
+	db := r.db.WithContext(ctx)
 	var product models.ProductDetail
-	err := r.db.Model(&models.Product{}).
+	err := db.Model(&models.Product{}).
 		Select("products.*, users.name as merchant_name").
 		Joins("left join users on products.merchant_id = users.id").
+		Scopes(withAssociations).
 		First(&product, "products.id = ?", id).Error
 	if err != nil {
 		return nil, err
@@ -39,48 +107,512 @@ func (r *productRepository) GetProductByID(id int64) (*models.ProductDetail, err
 	return &product, nil
 }
 
-func (r *productRepository) GetProductByMerchantID(id int64, page, limit int) ([]models.ProductDetail, int64, error) {
+func (r *productRepository) GetProductByMerchantID(ctx context.Context, id int64, cursor *models.Cursor, page, limit int) (*models.Page[models.ProductDetail], error) {
+	db := r.db.WithContext(ctx)
 	var total int64
 	var products []models.ProductDetail
 	offset := (page - 1) * limit
 
-	query := r.db.Model(&models.Product{}).
-		Select("products.*, users.name as merchant_name").
-		Joins("left join (?) as users on products.merchant_id = users.id", r.db.Model(&models.User{}).Where("role = 'merchant'"))
+	query := catalogProducts(db).Where("products.merchant_id = ?", id)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	query, err := paginate(query.Scopes(withMerchantName, withAssociations), productSortKeys(""), cursor, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	result := pageOf(products, cursor, offset, limit, productPosition(""))
+	result.Total = total
+	return result, nil
+}
+
+// UpdateProduct writes every editable column, including zero values such as
+// an empty description or a quantity of 0. The write only applies while the
+// stored version still equals product.Version; it then bumps the version,
+// and ErrVersionConflict is returned otherwise. The category links are
+// replaced with product.Categories.
+func (r *productRepository) UpdateProduct(ctx context.Context, product *models.Product) error {
+	// Map updates bypass the JSON serializer, so the options are encoded here.
+	options, err := json.Marshal(product.Options)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A stock change in between bumps the version, so the update below
+		// fails rather than recording a stale difference.
+		var previous int64
+		if err := tx.Model(&models.Product{}).Select("quantity").Where("id = ?", product.Id).Row().Scan(&previous); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrVersionConflict
+			}
+			return err
+		}
+
+		result := tx.Model(&models.Product{}).
+			Where("id = ? AND version = ?", product.Id, product.Version).
+			Updates(map[string]interface{}{
+				"sku":                 product.Sku,
+				"name":                product.Name,
+				"description":         product.Description,
+				"price":               product.Price,
+				"quantity":            product.Quantity,
+				"options":             string(options),
+				"low_stock_threshold": product.LowStockThreshold,
+				"version":             gorm.Expr("version + 1"),
+				"updated_at":          now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		if err := recordQuantityChange(tx, product.Id, nil, previous, product.Quantity, "Product update"); err != nil {
+			return err
+		}
+		return tx.Model(product).Omit("Categories.*").Association("Categories").Replace(product.Categories)
+	})
+	if err != nil {
+		return err
+	}
+
+	product.Version++
+	product.UpdatedAt = now
+	return nil
+}
+
+// SetArchived lists or unlists the product, with the same version check as
+// UpdateProduct.
+func (r *productRepository) SetArchived(ctx context.Context, product *models.Product, archived bool) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ? AND version = ?", product.Id, product.Version).
+		Updates(map[string]interface{}{
+			"archived":   archived,
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+
+	product.Archived = archived
+	product.Version++
+	product.UpdatedAt = now
+	return nil
+}
+
+// DeleteProduct soft-deletes the product if it is still at product.Version.
+// Transactions keep pointing at the row, so order history stays intact.
+func (r *productRepository) DeleteProduct(ctx context.Context, product *models.Product) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND version = ?", product.Id, product.Version).
+		Delete(&models.Product{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// RestoreProduct brings back one of the merchant's soft-deleted products. It
+// returns gorm.ErrRecordNotFound when there is no such deleted product.
+func (r *productRepository) RestoreProduct(ctx context.Context, merchantId, id int64) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("id = ? AND merchant_id = ? AND deleted_at IS NOT NULL", id, merchantId).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *productRepository) ListDeletedProducts(ctx context.Context, merchantId int64, page, limit int) ([]models.Product, int64, error) {
+	var total int64
+	var products []models.Product
+	query := r.db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("merchant_id = ? AND deleted_at IS NOT NULL", merchantId)
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	err := query.Limit(limit).Offset(offset).
-		Find(&products, "products.merchant_id = ?", id).Error
+	offset := (page - 1) * limit
+	err := query.Order("deleted_at DESC").Limit(limit).Offset(offset).Find(&products).Error
 	if err != nil {
 		return nil, 0, err
 	}
 	return products, total, nil
 }
 
-func (r *productRepository) UpdateProduct(product *models.Product) error {
-	return r.db.Model(product).Updates(product).Error
-}
+func (r *productRepository) PurgeDeletedProducts(ctx context.Context, before time.Time) (int64, []string, error) {
+	var purged int64
+	var blobKeys []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []int64
+		err := tx.Unscoped().Model(&models.Product{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Where("NOT EXISTS (?)", tx.Table("transactions").Select("1").Where("transactions.product_id = products.id")).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		dependents := []any{
+			&models.ProductCategory{},
+			&models.ProductVariant{},
+			&models.InventoryMovement{},
+			&models.StockReservation{},
+			&models.StockSubscription{},
+			&models.Notification{},
+			&models.WishlistItem{},
+		}
+		for _, dependent := range dependents {
+			if err := tx.Where("product_id IN ?", ids).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+
+		var images []models.ProductImage
+		if err := tx.Where("product_id IN ?", ids).Find(&images).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id IN ?", ids).Delete(&models.ProductImage{}).Error; err != nil {
+			return err
+		}
+		for _, image := range images {
+			blobKeys = append(blobKeys, image.Key, image.ThumbnailKey)
+		}
 
-func (r *productRepository) DeleteProduct(id uint) error {
-	return r.db.Delete(&models.Product{}, id).Error
+		result := tx.Unscoped().Delete(&models.Product{}, ids)
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return purged, blobKeys, nil
 }
 
-func (r *productRepository) ListProducts(filter models.ProductRequest) ([]models.ProductDetail, int64, error) {
+func (r *productRepository) ListProducts(ctx context.Context, filter models.ProductQuery) (*models.Page[models.ProductDetail], error) {
+	db := r.db.WithContext(ctx)
+	hits := filter.SearchIds
+	filter, err := withSearchMatches(db, filter)
+	if err != nil {
+		return nil, err
+	}
+
 	var total int64
 	var products []models.ProductDetail
-	query := r.db.Model(&models.Product{}).
-		Select("products.*, users.name as merchant_name").Debug().
-		Joins("left join (?) as users on products.merchant_id = users.id", r.db.Model(&models.User{}).Where("role = 'merchant'"))
+	query := filteredProducts(db, filter)
+
+	if filter.SearchIds != nil && filter.Sort == "" {
+		return listRanked(db, query, hits, filter)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Sort == models.ProductSortBestSelling {
+		sales := db.Model(&models.Transaction{}).
+			Select("product_id, SUM(quantity) AS units_sold").
+			Where("status = ?", models.TransactionStatusCompleted).
+			Group("product_id")
+		query = query.Joins("left join (?) as sales on sales.product_id = products.id", sales).
+			Select("products.*, users.name as merchant_name, COALESCE(sales.units_sold, 0) AS units_sold")
+	} else {
+		query = query.Scopes(withMerchantName)
+	}
+
+	offset := (filter.Page - 1) * filter.Limit
+	query, err = paginate(query.Scopes(withAssociations), productSortKeys(filter.Sort), filter.Position, offset, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	page := pageOf(products, filter.Position, offset, filter.Limit, productPosition(filter.Sort))
+	page.Total = total
+	return page, nil
+
+}
+
+// listRanked pages through the search hits that pass the other filters,
+// keeping their order in hits. Cursors hold the rank of a hit among all
+// hits, so it doesn't shift when other hits stop matching the filters.
+func listRanked(db, query *gorm.DB, hits []int64, filter models.ProductQuery) (*models.Page[models.ProductDetail], error) {
+	var matched []int64
+	if err := query.Pluck("products.id", &matched).Error; err != nil {
+		return nil, err
+	}
+
+	rank := make(map[int64]int, len(hits))
+	for i, id := range hits {
+		rank[id] = i
+	}
+	slices.SortFunc(matched, func(a, b int64) int { return rank[a] - rank[b] })
+	total := int64(len(matched))
+
+	// Mirror paginate on the ranked IDs: one extra hit is kept, and backward
+	// pages are read nearest first.
+	offset := (filter.Page - 1) * filter.Limit
+	cursor := filter.Position
+	if cursor != nil {
+		if len(cursor.Values) != 1 {
+			return nil, ErrInvalidCursor
+		}
+		at, _ := slices.BinarySearchFunc(matched, int(cursor.Values[0]), func(id int64, r int) int { return rank[id] - r })
+		if cursor.Backward {
+			matched = matched[:at]
+			slices.Reverse(matched)
+		} else {
+			for at < len(matched) && rank[matched[at]] <= int(cursor.Values[0]) {
+				at++
+			}
+			matched = matched[at:]
+		}
+		offset = 0
+	}
+	if offset >= len(matched) {
+		matched = nil
+	} else {
+		matched = matched[offset:min(offset+filter.Limit+1, len(matched))]
+	}
+
+	var products []models.ProductDetail
+	if len(matched) > 0 {
+		err := catalogProducts(db).Scopes(withMerchantName, withAssociations).Where("products.id IN ?", matched).Find(&products).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	order := make(map[int64]int, len(matched))
+	for i, id := range matched {
+		order[id] = i
+	}
+	slices.SortFunc(products, func(a, b models.ProductDetail) int { return order[a.Id] - order[b.Id] })
+
+	page := pageOf(products, cursor, offset, filter.Limit, func(p models.ProductDetail) models.Cursor {
+		return models.Cursor{Values: []float64{float64(rank[p.Id])}, Id: p.Id}
+	})
+	page.Total = total
+	return page, nil
+}
+
+// PriceFacetBounds are the upper bounds of the price facet buckets, in
+// ascending order. A final bucket holds everything above the last one.
+var PriceFacetBounds = []float64{50000, 100000, 250000, 500000, 1000000}
+
+// maxMerchantFacets bounds the merchant facet to the merchants with the most
+// matching products.
+const maxMerchantFacets = 20
+
+func (r *productRepository) ProductFacets(ctx context.Context, filter models.ProductQuery) (*models.ProductFacets, error) {
+	db := r.db.WithContext(ctx)
+	facets := &models.ProductFacets{}
+
+	// Each facet leaves out its own filter, so it matches the search hits
+	// against the others separately.
+	priceFilter := filter
+	priceFilter.Price, priceFilter.MinPrice, priceFilter.MaxPrice = 0, 0, 0
+	priceFilter, err := withSearchMatches(db, priceFilter)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := priceFacets(db, priceFilter)
+	if err != nil {
+		return nil, err
+	}
+	facets.Prices = prices
+
+	merchantFilter := filter
+	merchantFilter.MerchantId = 0
+	if merchantFilter, err = withSearchMatches(db, merchantFilter); err != nil {
+		return nil, err
+	}
+	facets.Merchants = []models.MerchantFacet{}
+	err = filteredProducts(db, merchantFilter).
+		Select("products.merchant_id, users.name AS merchant_name, COUNT(*) AS count").
+		Group("products.merchant_id, users.name").
+		Order("count DESC, products.merchant_id").
+		Limit(maxMerchantFacets).
+		Scan(&facets.Merchants).Error
+	if err != nil {
+		return nil, err
+	}
+
+	categoryFilter := filter
+	categoryFilter.CategoryIds = nil
+	if categoryFilter, err = withSearchMatches(db, categoryFilter); err != nil {
+		return nil, err
+	}
+	categories, err := categoryFacets(db, categoryFilter)
+	if err != nil {
+		return nil, err
+	}
+	facets.Categories = categories
+
+	return facets, nil
+}
+
+func priceFacets(db *gorm.DB, filter models.ProductQuery) ([]models.PriceFacet, error) {
+	bucket := "CASE"
+	args := make([]interface{}, 0, len(PriceFacetBounds))
+	for i, bound := range PriceFacetBounds {
+		bucket += fmt.Sprintf(" WHEN products.price < ? THEN %d", i)
+		args = append(args, bound)
+	}
+	bucket += fmt.Sprintf(" ELSE %d END", len(PriceFacetBounds))
+
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	err := filteredProducts(db, filter).
+		Select(bucket+" AS bucket, COUNT(*) AS count", args...).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	facets := make([]models.PriceFacet, len(PriceFacetBounds)+1)
+	for i := range facets {
+		if i > 0 {
+			facets[i].Min = PriceFacetBounds[i-1]
+		}
+		if i < len(PriceFacetBounds) {
+			facets[i].Max = &PriceFacetBounds[i]
+		}
+	}
+	for _, row := range rows {
+		if row.Bucket >= 0 && row.Bucket < len(facets) {
+			facets[row.Bucket].Count = row.Count
+		}
+	}
+	return facets, nil
+}
+
+// categoryFacets counts matching products per category subtree, like
+// CategoryTree does for the whole catalog. Categories without matches are
+// left out.
+func categoryFacets(db *gorm.DB, filter models.ProductQuery) ([]models.CategoryFacet, error) {
+	var categories []models.Category
+	if err := db.Scopes(orderCategories).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	var links []models.ProductCategory
+	err := db.Model(&models.ProductCategory{}).
+		Where("product_id IN (?)", filteredProducts(db, filter).Select("products.id")).
+		Find(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int64]models.Category, len(categories))
+	for _, category := range categories {
+		byId[category.Id] = category
+	}
+	productsByCategory := map[int64]map[int64]struct{}{}
+	for _, link := range links {
+		for id, depth := link.CategoryId, 0; depth < len(byId); depth++ {
+			category, ok := byId[id]
+			if !ok {
+				break
+			}
+			if productsByCategory[id] == nil {
+				productsByCategory[id] = map[int64]struct{}{}
+			}
+			productsByCategory[id][link.ProductId] = struct{}{}
+			if category.ParentId == nil {
+				break
+			}
+			id = *category.ParentId
+		}
+	}
+
+	facets := []models.CategoryFacet{}
+	for _, category := range categories {
+		if count := len(productsByCategory[category.Id]); count > 0 {
+			facets = append(facets, models.CategoryFacet{
+				CategoryId: category.Id,
+				ParentId:   category.ParentId,
+				Name:       category.Name,
+				Slug:       category.Slug,
+				Count:      int64(count),
+			})
+		}
+	}
+	return facets, nil
+}
+
+// withSearchMatches narrows filter.SearchIds, every hit of the search best
+// first, to the first maxSearchMatches that pass the other filters. Capping
+// only after filtering means a narrow filter still finds its matches among
+// the many hits of a common word.
+func withSearchMatches(db *gorm.DB, filter models.ProductQuery) (models.ProductQuery, error) {
+	hits := filter.SearchIds
+	if len(hits) <= maxSearchMatches {
+		return filter, nil
+	}
+
+	matches := make([]int64, 0, maxSearchMatches)
+	for start := 0; start < len(hits) && len(matches) < maxSearchMatches; start += searchChunkSize {
+		chunk := filter
+		chunk.SearchIds = hits[start:min(start+searchChunkSize, len(hits))]
+		var found []int64
+		if err := filteredProducts(db, chunk).Pluck("products.id", &found).Error; err != nil {
+			return filter, err
+		}
+		passed := make(map[int64]bool, len(found))
+		for _, id := range found {
+			passed[id] = true
+		}
+		for _, id := range chunk.SearchIds {
+			if passed[id] && len(matches) < maxSearchMatches {
+				matches = append(matches, id)
+			}
+		}
+	}
+	filter.SearchIds = matches
+	return filter, nil
+}
+
+// filteredProducts applies every catalog filter of the query. It joins the
+// merchants as users so they can be filtered and grouped by name.
+func filteredProducts(db *gorm.DB, filter models.ProductQuery) *gorm.DB {
+	query := catalogProducts(db).Where("products.archived = ?", false)
 
 	if filter.Name != "" {
-		query = query.Where("products.name LIKE ?", "%"+filter.Name+"%")
+		query = whereContains(query, "products.name", filter.Name)
 	}
 
 	if filter.Description != "" {
-		query = query.Where("products.description LIKE ?", "%"+filter.Description+"%")
+		query = whereContains(query, "products.description", filter.Description)
 	}
 
 	if filter.MinPrice > 0 {
@@ -96,19 +628,165 @@ func (r *productRepository) ListProducts(filter models.ProductRequest) ([]models
 	}
 
 	if filter.MerchantName != "" {
-		query = query.Where("users.name LIKE ?", "%"+filter.MerchantName+"%")
+		query = whereContains(query, "users.name", filter.MerchantName)
 	}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	if filter.MerchantId > 0 {
+		query = query.Where("products.merchant_id = ?", filter.MerchantId)
 	}
 
-	offset := (filter.Page - 1) * filter.Limit
-	err := query.Limit(filter.Limit).Offset(offset).Find(&products).Error
+	if filter.CategoryIds != nil {
+		query = whereInCategories(query, filter.CategoryIds)
+	}
+
+	if filter.InStock {
+		query = whereInStock(query)
+	}
+
+	if filter.SearchIds != nil {
+		if len(filter.SearchIds) == 0 {
+			query = query.Where("1 = 0")
+		} else {
+			query = query.Where("products.id IN ?", filter.SearchIds)
+		}
+	}
+
+	return query
+}
+
+// productSortKeys returns the order of one of the models.ProductSort
+// values, newest first by default. Ties fall back to the newest product so
+// that pages are stable.
+func productSortKeys(sort string) []sortKey {
+	newest := sortKey{"products.id", true}
+	switch sort {
+	case models.ProductSortPriceAsc:
+		return []sortKey{{"products.price", false}, newest}
+	case models.ProductSortPriceDesc:
+		return []sortKey{{"products.price", true}, newest}
+	case models.ProductSortBestSelling:
+		return []sortKey{{"COALESCE(sales.units_sold, 0)", true}, newest}
+	case models.ProductSortRating:
+		return []sortKey{{"products.rating_avg", true}, {"products.rating_count", true}, newest}
+	default:
+		return []sortKey{newest}
+	}
+}
+
+// productPosition returns the cursor of a product under productSortKeys.
+func productPosition(sort string) func(models.ProductDetail) models.Cursor {
+	return func(p models.ProductDetail) models.Cursor {
+		cursor := models.Cursor{Id: p.Id}
+		switch sort {
+		case models.ProductSortPriceAsc, models.ProductSortPriceDesc:
+			cursor.Values = []float64{p.Price}
+		case models.ProductSortBestSelling:
+			cursor.Values = []float64{float64(p.UnitsSold)}
+		case models.ProductSortRating:
+			cursor.Values = []float64{p.RatingAvg, float64(p.RatingCount)}
+		}
+		return cursor
+	}
+}
+
+// catalogProducts joins products to their merchants.
+func catalogProducts(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Product{}).
+		Joins("left join (?) as users on products.merchant_id = users.id", db.Model(&models.User{}).Where("role = 'merchant'"))
+}
+
+func withMerchantName(db *gorm.DB) *gorm.DB {
+	return db.Select("products.*, users.name as merchant_name")
+}
+
+func (r *productRepository) SkuTaken(ctx context.Context, merchantId int64, sku string, exceptId int64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("merchant_id = ? AND sku = ? AND id <> ?", merchantId, sku, exceptId).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *productRepository) GetProductBySku(ctx context.Context, merchantId int64, sku string) (*models.Product, error) {
+	var product models.Product
+	err := r.db.WithContext(ctx).Unscoped().
+		Preload("Categories", orderCategories).
+		Preload("Variants", orderVariants).
+		Where("merchant_id = ? AND sku = ?", merchantId, sku).
+		First(&product).Error
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
+	return &product, nil
+}
 
-	return products, total, nil
+func (r *productRepository) ListMerchantProducts(ctx context.Context, merchantId, afterId int64, limit int) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.WithContext(ctx).
+		Preload("Categories", orderCategories).
+		Where("merchant_id = ? AND id > ?", merchantId, afterId).
+		Order("id").
+		Limit(limit).
+		Find(&products).Error
+	return products, err
+}
+
+func (r *productRepository) GetProductsByIDs(ctx context.Context, ids []int64) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.WithContext(ctx).
+		Preload("Variants", orderVariants).
+		Where("id IN ?", ids).
+		Find(&products).Error
+	return products, err
+}
+
+func (r *productRepository) ListSearchable(ctx context.Context, afterId int64, limit int) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.WithContext(ctx).
+		Select("id", "name", "description").
+		Where("id > ?", afterId).
+		Order("id").
+		Limit(limit).
+		Find(&products).Error
+	return products, err
+}
+
+// whereInCategories keeps products linked to any of the given categories. An
+// empty list matches nothing.
+func whereInCategories(query *gorm.DB, categoryIds []int64) *gorm.DB {
+	if len(categoryIds) == 0 {
+		return query.Where("1 = 0")
+	}
+	return query.Where("products.id IN (?)",
+		query.Session(&gorm.Session{NewDB: true}).Model(&models.ProductCategory{}).Select("product_id").Where("category_id IN ?", categoryIds))
+}
+
+// whereInStock keeps products that can be bought: products with variants
+// need a variant in stock, others need stock of their own.
+func whereInStock(query *gorm.DB) *gorm.DB {
+	variants := func() *gorm.DB {
+		return query.Session(&gorm.Session{NewDB: true}).Model(&models.ProductVariant{}).
+			Select("1").Where("product_variants.product_id = products.id")
+	}
+	return query.Where("(products.quantity > 0 AND NOT EXISTS (?)) OR EXISTS (?)",
+		variants(), variants().Where("product_variants.quantity > 0"))
+}
+
+// withAssociations loads what product responses show next to the columns.
+func withAssociations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Categories", orderCategories).
+		Preload("Variants", orderVariants).
+		Preload("Images", orderImages)
+}
+
+func orderImages(db *gorm.DB) *gorm.DB {
+	return db.Order("product_images.position, product_images.id")
+}
+
+func orderVariants(db *gorm.DB) *gorm.DB {
+	return db.Order("product_variants.id")
+}
 
+func orderCategories(db *gorm.DB) *gorm.DB {
+	return db.Order("categories.position, categories.name")
 }
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/models"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestListProductsFiltersSearchHitsBeforeCapping(t *testing.T) {
+	db := newTestDB(t)
+	db.Create([]models.User{{Id: 1, Name: "Big", Role: "merchant"}, {Id: 2, Name: "Small", Role: "merchant"}})
+
+	// The small merchant's products rank below more than maxSearchMatches
+	// hits of the big one.
+	var products []models.Product
+	for i := range 3 {
+		products = append(products, models.Product{Name: fmt.Sprintf("Shirt %d", i), Price: 100, Quantity: 1, MerchantId: 2})
+	}
+	for i := range maxSearchMatches + 200 {
+		products = append(products, models.Product{Name: fmt.Sprintf("Shirt %d", i), Price: 100, Quantity: 1, MerchantId: 1})
+	}
+	if err := db.CreateInBatches(products, 200).Error; err != nil {
+		t.Fatalf("create products: %v", err)
+	}
+	var hits []int64
+	for _, product := range products {
+		hits = append(hits, product.Id)
+	}
+	slices.Reverse(hits)
+
+	repo := NewProductRepository(db)
+	filter := models.ProductQuery{Page: 1, Limit: 10, MerchantId: 2, SearchIds: hits}
+	page, err := repo.ListProducts(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+	var got []int64
+	for _, product := range page.Items {
+		got = append(got, product.Id)
+	}
+	if want := []int64{products[2].Id, products[1].Id, products[0].Id}; page.Total != 3 || !slices.Equal(got, want) {
+		t.Errorf("ListProducts = %v of %d, want %v of 3", got, page.Total, want)
+	}
+
+	facets, err := repo.ProductFacets(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("ProductFacets: %v", err)
+	}
+	var priced int64
+	for _, facet := range facets.Prices {
+		priced += facet.Count
+	}
+	if priced != 3 {
+		t.Errorf("price facets count %d products, want 3", priced)
+	}
+}
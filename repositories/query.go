@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// likeEscaper escapes the LIKE wildcards so user input is matched literally.
+// '!' is used as the escape character because a backslash inside a string
+// literal is itself an escape on MySQL but not on PostgreSQL or SQLite.
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+// whereContains adds a case-insensitive substring match on column. LIKE is
+// case-insensitive on MySQL but not on PostgreSQL, so both sides are lowered
+// to behave the same on every driver.
+func whereContains(query *gorm.DB, column, value string) *gorm.DB {
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(value)) + "%"
+	return query.Where("LOWER("+column+") LIKE ? ESCAPE '!'", pattern)
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/migrations"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB returns a migrated database in a fresh SQLite file.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Open(&database.DBConfig{
+		Driver:       database.DriverSQLite,
+		DBName:       filepath.Join(t.TempDir(), "shop.db"),
+		MaxOpenConns: 1,
+		MaxIdleConns: 1,
+	}, logger.Discard)
+	if err != nil {
+		t.Fatalf("database.Open: %v", err)
+	}
+	migrations.Migrate(db)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
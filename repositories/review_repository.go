@@ -0,0 +1,254 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReviewRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() ReviewRepository
+	// CreateReview fails with ErrNotVerifiedBuyer unless the customer has a
+	// completed transaction for the product, and with ErrReviewExists if
+	// they already reviewed it.
+	CreateReview(ctx context.Context, review *models.Review) error
+	// UpdateReview changes the rating and text of the customer's review.
+	UpdateReview(ctx context.Context, review *models.Review) error
+	DeleteReview(ctx context.Context, customerId, productId, id int64) error
+	GetReview(ctx context.Context, productId, id int64) (*models.ReviewDetail, error)
+	// ListReviews returns a page of the product's published reviews.
+	ListReviews(ctx context.Context, productId int64, filter models.ReviewQuery) ([]models.ReviewDetail, int64, error)
+	ReplyToReview(ctx context.Context, productId, id int64, reply string) error
+	// FlagReview reports a published review. Flagging it again replaces the
+	// user's earlier flag.
+	FlagReview(ctx context.Context, flag *models.ReviewFlag) error
+	// ListFlaggedReviews returns reviews with open flags, most flagged first.
+	ListFlaggedReviews(ctx context.Context, page, limit int) ([]models.FlaggedReview, int64, error)
+	// ModerateReview sets the review's status and resolves its flags.
+	ModerateReview(ctx context.Context, id int64, status string) error
+}
+
+// ErrNotVerifiedBuyer means the customer never completed a purchase of the
+// product they are reviewing.
+var ErrNotVerifiedBuyer = errors.New("customer has not bought the product")
+
+// ErrReviewExists means the customer already reviewed the product.
+var ErrReviewExists = errors.New("customer already reviewed the product")
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
+
+func (r *reviewRepository) UsePrimary() ReviewRepository {
+	return &reviewRepository{db: database.Primary(r.db)}
+}
+
+func (r *reviewRepository) CreateReview(ctx context.Context, review *models.Review) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var purchased int64
+		err := tx.Model(&models.Transaction{}).
+			Where("customer_id = ? AND product_id = ? AND status = ?", review.CustomerId, review.ProductId, models.TransactionStatusCompleted).
+			Count(&purchased).Error
+		if err != nil {
+			return err
+		}
+		if purchased == 0 {
+			return ErrNotVerifiedBuyer
+		}
+
+		// The unique index decides, so concurrent duplicates can't both
+		// get through.
+		review.Status = models.ReviewPublished
+		if err := tx.Create(review).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrReviewExists
+			}
+			return err
+		}
+		return refreshRating(tx, review.ProductId)
+	})
+}
+
+func (r *reviewRepository) UpdateReview(ctx context.Context, review *models.Review) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Review{}).
+			Where("id = ? AND product_id = ? AND customer_id = ?", review.Id, review.ProductId, review.CustomerId).
+			Updates(map[string]interface{}{
+				"rating":     review.Rating,
+				"body":       review.Body,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return refreshRating(tx, review.ProductId)
+	})
+}
+
+func (r *reviewRepository) DeleteReview(ctx context.Context, customerId, productId, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND product_id = ? AND customer_id = ?", id, productId, customerId).Delete(&models.Review{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("review_id = ?", id).Delete(&models.ReviewFlag{}).Error; err != nil {
+			return err
+		}
+		return refreshRating(tx, productId)
+	})
+}
+
+func (r *reviewRepository) GetReview(ctx context.Context, productId, id int64) (*models.ReviewDetail, error) {
+	var review models.ReviewDetail
+	err := reviewDetails(r.db.WithContext(ctx)).
+		Where("reviews.id = ? AND reviews.product_id = ?", id, productId).
+		First(&review).Error
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) ListReviews(ctx context.Context, productId int64, filter models.ReviewQuery) ([]models.ReviewDetail, int64, error) {
+	query := reviewDetails(r.db.WithContext(ctx)).
+		Where("reviews.product_id = ? AND reviews.status = ?", productId, models.ReviewPublished)
+	if filter.Rating > 0 {
+		query = query.Where("reviews.rating = ?", filter.Rating)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	switch filter.Sort {
+	case models.ReviewSortOldest:
+		query = query.Order("reviews.id ASC")
+	case models.ReviewSortRatingDesc:
+		query = query.Order("reviews.rating DESC").Order("reviews.id DESC")
+	case models.ReviewSortRatingAsc:
+		query = query.Order("reviews.rating ASC").Order("reviews.id DESC")
+	default:
+		query = query.Order("reviews.id DESC")
+	}
+
+	var reviews []models.ReviewDetail
+	err := query.Offset((filter.Page - 1) * filter.Limit).Limit(filter.Limit).Find(&reviews).Error
+	return reviews, total, err
+}
+
+func (r *reviewRepository) ReplyToReview(ctx context.Context, productId, id int64, reply string) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.Review{}).
+		Where("id = ? AND product_id = ?", id, productId).
+		Updates(map[string]interface{}{"reply": reply, "replied_at": now, "updated_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *reviewRepository) FlagReview(ctx context.Context, flag *models.ReviewFlag) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var published int64
+		err := tx.Model(&models.Review{}).
+			Where("id = ? AND status = ?", flag.ReviewId, models.ReviewPublished).
+			Count(&published).Error
+		if err != nil {
+			return err
+		}
+		if published == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "review_id"}, {Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"reason":      flag.Reason,
+				"note":        flag.Note,
+				"resolved_at": nil,
+			}),
+		}).Create(flag).Error
+	})
+}
+
+func (r *reviewRepository) ListFlaggedReviews(ctx context.Context, page, limit int) ([]models.FlaggedReview, int64, error) {
+	openFlags := r.db.Model(&models.ReviewFlag{}).
+		Select("review_id, COUNT(*) as flags").
+		Where("resolved_at IS NULL").
+		Group("review_id")
+	query := reviewDetails(r.db.WithContext(ctx)).
+		Joins("join (?) as open_flags on open_flags.review_id = reviews.id", openFlags)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var reviews []models.FlaggedReview
+	err := query.Select("reviews.*, customers.name as customer_name, open_flags.flags").
+		Order("open_flags.flags DESC").Order("reviews.id").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&reviews).Error
+	return reviews, total, err
+}
+
+func (r *reviewRepository) ModerateReview(ctx context.Context, id int64, status string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var review models.Review
+		if err := tx.First(&review, id).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&review).Updates(map[string]interface{}{"status": status, "updated_at": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.ReviewFlag{}).
+			Where("review_id = ? AND resolved_at IS NULL", id).
+			Update("resolved_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return refreshRating(tx, review.ProductId)
+	})
+}
+
+// reviewDetails selects reviews with their author's name.
+func reviewDetails(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Review{}).
+		Select("reviews.*, customers.name as customer_name").
+		Joins("left join users as customers on reviews.customer_id = customers.id")
+}
+
+// refreshRating recomputes the product's rating from its published reviews.
+// The version is left alone, since the rating isn't the merchant's edit;
+// product ETags include the rating separately, so they still change.
+func refreshRating(tx *gorm.DB, productId int64) error {
+	published := func() *gorm.DB {
+		return tx.Model(&models.Review{}).Where("product_id = ? AND status = ?", productId, models.ReviewPublished)
+	}
+	return tx.Unscoped().Model(&models.Product{}).Where("id = ?", productId).
+		UpdateColumns(map[string]interface{}{
+			"rating_avg":   gorm.Expr("(?)", published().Select("COALESCE(ROUND(AVG(rating), 2), 0)")),
+			"rating_count": gorm.Expr("(?)", published().Select("COUNT(*)")),
+		}).Error
+}
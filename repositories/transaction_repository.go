@@ -1,18 +1,55 @@
 package repositories
 
 import (
+	"backend-hanssen-hilman/database"
 	"backend-hanssen-hilman/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type TransactionRepository interface {
-	CreateTransaction(transaction *models.Transaction) error
-	GetTransactionByID(id int64) (*models.TransactionResponse, error)
-	ListTransactionsByMerchantID(merchantId int64, limit, page int) ([]models.TransactionResponse, int64, error)
-	ListTransactionsByCustomerID(customerId int64, limit, page int) ([]models.TransactionResponse, int64, error)
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() TransactionRepository
+	CreateTransaction(ctx context.Context, transaction *models.Transaction) error
+	// Checkout creates the transaction as pending and reserves its stock
+	// until expiresAt. ErrInsufficientStock means there wasn't enough.
+	Checkout(ctx context.Context, transaction *models.Transaction, expiresAt time.Time) (*models.StockReservation, error)
+	// CheckoutAll checks out several transactions at once. Either all of
+	// them get their stock reserved or none do.
+	CheckoutAll(ctx context.Context, transactions []*models.Transaction, expiresAt time.Time) ([]models.StockReservation, error)
+	// CompletePayment turns the reservation of the customer's pending
+	// transaction into a sale. It fails with ErrReservationExpired once the
+	// reservation has run out, and with ErrTransactionStatus for
+	// transactions that aren't pending.
+	CompletePayment(ctx context.Context, customerId, id int64) error
+	// CancelTransaction releases the reservation of the customer's pending
+	// transaction.
+	CancelTransaction(ctx context.Context, customerId, id int64) error
+	// RefundTransaction marks a completed transaction of the merchant's
+	// products as refunded and returns its stock.
+	RefundTransaction(ctx context.Context, merchantId, id int64) error
+	// ReleaseExpiredReservations cancels up to limit pending transactions
+	// whose reservation expired before now, and returns how many it did.
+	ReleaseExpiredReservations(ctx context.Context, now time.Time, limit int) (int, error)
+	GetTransactionByID(ctx context.Context, id int64) (*models.TransactionResponse, error)
+	// The listings return the page at filter.Position, or at filter.Page
+	// without a cursor.
+	ListTransactionsByMerchantID(ctx context.Context, merchantId int64, filter models.TransactionQuery) (*models.Page[models.TransactionResponse], error)
+	ListTransactionsByCustomerID(ctx context.Context, customerId int64, filter models.TransactionQuery) (*models.Page[models.TransactionResponse], error)
 }
 
+// ErrTransactionStatus means the transaction isn't in the status the
+// operation applies to, such as paying for a cancelled checkout.
+var ErrTransactionStatus = errors.New("transaction is not in the required status")
+
+// ErrReservationExpired means a checkout was paid for too late.
+var ErrReservationExpired = errors.New("stock reservation has expired")
+
 type transactionRepository struct {
 	db *gorm.DB
 }
@@ -21,57 +58,328 @@ func NewTransactionRepository(db *gorm.DB) TransactionRepository {
 	return &transactionRepository{db: db}
 }
 
-func (r *transactionRepository) CreateTransaction(transaction *models.Transaction) error {
-	return r.db.Create(transaction).Error
+func (r *transactionRepository) UsePrimary() TransactionRepository {
+	return &transactionRepository{db: database.Primary(r.db)}
 }
 
-func (r *transactionRepository) GetTransactionByID(id int64) (*models.TransactionResponse, error) {
-	var transaction models.TransactionResponse
-	err := r.db.Model(&models.Transaction{}).
-		Select("transactions.id, transactions.product_id, products.name as product_name, transactions.quantity, transactions.total_price, users.name as customer").
-		Joins("left join products on transactions.product_id = products.id").
-		Joins("left join users on transactions.customer_id = users.id").
-		First(&transaction, "transactions.id = ?", id).Error
+func (r *transactionRepository) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	return r.db.WithContext(ctx).Create(transaction).Error
+}
+
+func (r *transactionRepository) Checkout(ctx context.Context, transaction *models.Transaction, expiresAt time.Time) (*models.StockReservation, error) {
+	reservations, err := r.CheckoutAll(ctx, []*models.Transaction{transaction}, expiresAt)
 	if err != nil {
 		return nil, err
 	}
-	return &transaction, nil
+	return &reservations[0], nil
 }
 
-func (r *transactionRepository) ListTransactionsByMerchantID(merchantId int64, limit, page int) ([]models.TransactionResponse, int64, error) {
-	var transactions []models.TransactionResponse
-	var total int64
-	offset := (page - 1) * limit
+func (r *transactionRepository) CheckoutAll(ctx context.Context, transactions []*models.Transaction, expiresAt time.Time) ([]models.StockReservation, error) {
+	reservations := make([]models.StockReservation, len(transactions))
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, transaction := range transactions {
+			transaction.Status = models.TransactionStatusPending
+			if err := tx.Create(transaction).Error; err != nil {
+				return err
+			}
 
-	query := r.db.Model(&models.Transaction{}).
-		Select("transactions.id, transactions.product_id, products.name as product_name, transactions.quantity, transactions.total_price, users.name as customer").
-		Joins("left join products on transactions.product_id = products.id").
-		Joins("left join (?) as users on transactions.customer_id = users.id", r.db.Model(&models.User{}).Where("role = 'customer'"))
+			reservations[i] = models.StockReservation{
+				TransactionId: transaction.Id,
+				ProductId:     transaction.ProductId,
+				VariantId:     transaction.VariantId,
+				Quantity:      transaction.Quantity,
+				Status:        models.ReservationActive,
+				ExpiresAt:     expiresAt,
+			}
+			if err := tx.Create(&reservations[i]).Error; err != nil {
+				return err
+			}
+			err := applyMovement(tx, reservationMovement(&reservations[i], models.MovementReservation, -reservations[i].Quantity))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// CompletePayment releases the reservation and records the sale, so a paid
+// checkout shows up in the ledger as a sale like any other.
+func (r *transactionRepository) CompletePayment(ctx context.Context, customerId, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		reservation, err := pendingReservation(tx, customerId, id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.StockReservation{}).
+			Where("id = ? AND status = ? AND expires_at > ?", reservation.Id, models.ReservationActive, now).
+			Updates(map[string]interface{}{"status": models.ReservationConsumed, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrReservationExpired
+		}
+
+		if err := applyMovement(tx, reservationMovement(reservation, models.MovementRelease, reservation.Quantity)); err != nil {
+			return err
+		}
+		if err := applyMovement(tx, reservationMovement(reservation, models.MovementSale, -reservation.Quantity)); err != nil {
+			return err
+		}
+		return setTransactionStatus(tx, id, models.TransactionStatusPending, models.TransactionStatusCompleted)
+	})
+}
 
-	err := query.
-		Limit(limit).Offset(offset).Where("products.merchant_id = ?", merchantId).
-		Find(&transactions).Error
+func (r *transactionRepository) CancelTransaction(ctx context.Context, customerId, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		reservation, err := pendingReservation(tx, customerId, id)
+		if err != nil {
+			return err
+		}
+		return releaseReservation(tx, reservation)
+	})
+}
+
+func (r *transactionRepository) RefundTransaction(ctx context.Context, merchantId, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var transaction models.Transaction
+		err := tx.Joins("join products on products.id = transactions.product_id").
+			Where("transactions.id = ? AND products.merchant_id = ?", id, merchantId).
+			First(&transaction).Error
+		if err != nil {
+			return err
+		}
+		if err := setTransactionStatus(tx, id, models.TransactionStatusCompleted, models.TransactionStatusRefunded); err != nil {
+			return err
+		}
+		return applyMovement(tx, &models.InventoryMovement{
+			ProductId:     transaction.ProductId,
+			VariantId:     transaction.VariantId,
+			Reason:        models.MovementRefund,
+			Delta:         transaction.Quantity,
+			TransactionId: &transaction.Id,
+		})
+	})
+}
+
+func (r *transactionRepository) ReleaseExpiredReservations(ctx context.Context, now time.Time, limit int) (int, error) {
+	var reservations []models.StockReservation
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at <= ?", models.ReservationActive, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&reservations).Error
 	if err != nil {
-		return nil, 0, err
+		return 0, err
 	}
-	return transactions, total, nil
+
+	// One reservation that can't be released mustn't hold up the ones
+	// behind it, so failures are logged and skipped.
+	released, failed := 0, 0
+	for i := range reservations {
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return releaseReservation(tx, &reservations[i])
+		})
+		// A payment or cancellation can get there first.
+		if errors.Is(err, ErrTransactionStatus) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return released, err
+			}
+			slog.ErrorContext(ctx, "Failed to release expired reservation",
+				"reservation_id", reservations[i].Id, "transaction_id", reservations[i].TransactionId, "error", err)
+			failed++
+			continue
+		}
+		released++
+	}
+	if failed > 0 {
+		return released, fmt.Errorf("%d of %d expired reservations could not be released", failed, len(reservations))
+	}
+	return released, nil
 }
 
-func (r *transactionRepository) ListTransactionsByCustomerID(customerId int64, limit, page int) ([]models.TransactionResponse, int64, error) {
-	var transactions []models.TransactionResponse
+// pendingReservation loads the reservation of the customer's transaction,
+// which must be pending.
+func pendingReservation(tx *gorm.DB, customerId, id int64) (*models.StockReservation, error) {
+	var transaction models.Transaction
+	if err := tx.Where("id = ? AND customer_id = ?", id, customerId).First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	if transaction.Status != models.TransactionStatusPending {
+		return nil, ErrTransactionStatus
+	}
+
+	var reservation models.StockReservation
+	if err := tx.Where("transaction_id = ?", transaction.Id).First(&reservation).Error; err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// releaseReservation gives the reserved stock back and cancels the
+// transaction. Stock whose variant has since been removed has nowhere to go
+// back to, but the transaction is still cancelled.
+func releaseReservation(tx *gorm.DB, reservation *models.StockReservation) error {
+	result := tx.Model(&models.StockReservation{}).
+		Where("id = ? AND status = ?", reservation.Id, models.ReservationActive).
+		Updates(map[string]interface{}{"status": models.ReservationReleased, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTransactionStatus
+	}
+
+	err := applyMovement(tx, reservationMovement(reservation, models.MovementRelease, reservation.Quantity))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return setTransactionStatus(tx, reservation.TransactionId, models.TransactionStatusPending, models.TransactionStatusCancelled)
+}
+
+func reservationMovement(reservation *models.StockReservation, reason string, delta int64) *models.InventoryMovement {
+	return &models.InventoryMovement{
+		ProductId:     reservation.ProductId,
+		VariantId:     reservation.VariantId,
+		Reason:        reason,
+		Delta:         delta,
+		TransactionId: &reservation.TransactionId,
+	}
+}
+
+// setTransactionStatus moves the transaction from one status to another,
+// failing with ErrTransactionStatus if it is no longer in from.
+func setTransactionStatus(tx *gorm.DB, id int64, from, to string) error {
+	result := tx.Model(&models.Transaction{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{"status": to, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTransactionStatus
+	}
+	return nil
+}
+
+func (r *transactionRepository) GetTransactionByID(ctx context.Context, id int64) (*models.TransactionResponse, error) {
+	var transaction models.TransactionResponse
+	err := transactionDetails(r.db.WithContext(ctx)).First(&transaction, "transactions.id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *transactionRepository) ListTransactionsByMerchantID(ctx context.Context, merchantId int64, filter models.TransactionQuery) (*models.Page[models.TransactionResponse], error) {
+	query := filteredTransactions(r.db.WithContext(ctx), filter).Where("products.merchant_id = ?", merchantId)
+	if filter.CustomerId > 0 {
+		query = query.Where("transactions.customer_id = ?", filter.CustomerId)
+	}
+	return listTransactions(query, filter)
+}
+
+func (r *transactionRepository) ListTransactionsByCustomerID(ctx context.Context, customerId int64, filter models.TransactionQuery) (*models.Page[models.TransactionResponse], error) {
+	query := filteredTransactions(r.db.WithContext(ctx), filter).Where("transactions.customer_id = ?", customerId)
+	return listTransactions(query, filter)
+}
+
+func listTransactions(query *gorm.DB, filter models.TransactionQuery) (*models.Page[models.TransactionResponse], error) {
 	var total int64
-	offset := (page - 1) * limit
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var transactions []models.TransactionResponse
+	offset := (filter.Page - 1) * filter.Limit
+	query, err := paginate(query, transactionSortKeys(filter.Sort), filter.Position, offset, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, err
+	}
 
-	query := r.db.Model(&models.Transaction{}).
-		Select("transactions.id, transactions.product_id, products.name as product_name, transactions.quantity, transactions.total_price, users.name as merchant, transactions.created_at, transactions.updated_at").
+	page := pageOf(transactions, filter.Position, offset, filter.Limit, transactionPosition(filter.Sort))
+	page.Total = total
+	return page, nil
+}
+
+// transactionDetails selects transactions with the names of their product,
+// customer and merchant.
+func transactionDetails(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Transaction{}).
+		Select("transactions.id, transactions.product_id, products.name as product_name, transactions.variant_id, transactions.sku, transactions.quantity, transactions.total_price, " +
+			"customers.name as customer, merchants.name as merchant, products.merchant_id, transactions.status, transactions.created_at, transactions.updated_at").
 		Joins("left join products on transactions.product_id = products.id").
-		Joins("left join (?) as users on products.merchant_id = users.id", r.db.Model(&models.User{}).Where("role = 'merchant'"))
+		Joins("left join users as customers on transactions.customer_id = customers.id").
+		Joins("left join users as merchants on products.merchant_id = merchants.id")
+}
 
-	err := query.
-		Limit(limit).Offset(offset).Where("transactions.customer_id = ?", customerId).
-		Find(&transactions).Error
-	if err != nil {
-		return nil, 0, err
+func filteredTransactions(db *gorm.DB, filter models.TransactionQuery) *gorm.DB {
+	query := transactionDetails(db)
+
+	if filter.From != nil {
+		query = query.Where("transactions.created_at >= ?", *filter.From)
+	}
+
+	if filter.To != nil {
+		query = query.Where("transactions.created_at <= ?", *filter.To)
+	}
+
+	if filter.ProductId > 0 {
+		query = query.Where("transactions.product_id = ?", filter.ProductId)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("transactions.status = ?", filter.Status)
+	}
+
+	if filter.MinAmount > 0 {
+		query = query.Where("transactions.total_price >= ?", filter.MinAmount)
+	}
+
+	if filter.MaxAmount > 0 {
+		query = query.Where("transactions.total_price <= ?", filter.MaxAmount)
+	}
+
+	return query
+}
+
+// transactionSortKeys returns the order of one of the
+// models.TransactionSort values, newest first by default.
+func transactionSortKeys(sort string) []sortKey {
+	newest := sortKey{"transactions.id", true}
+	switch sort {
+	case models.TransactionSortOldest:
+		return []sortKey{{"transactions.id", false}}
+	case models.TransactionSortAmountAsc:
+		return []sortKey{{"transactions.total_price", false}, newest}
+	case models.TransactionSortAmountDesc:
+		return []sortKey{{"transactions.total_price", true}, newest}
+	default:
+		return []sortKey{newest}
+	}
+}
+
+// transactionPosition returns the cursor of a transaction under
+// transactionSortKeys.
+func transactionPosition(sort string) func(models.TransactionResponse) models.Cursor {
+	return func(t models.TransactionResponse) models.Cursor {
+		cursor := models.Cursor{Id: t.Id}
+		if sort == models.TransactionSortAmountAsc || sort == models.TransactionSortAmountDesc {
+			cursor.Values = []float64{t.TotalPrice}
+		}
+		return cursor
 	}
-	return transactions, total, nil
 }
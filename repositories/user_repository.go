@@ -1,15 +1,19 @@
 package repositories
 
 import (
+	"backend-hanssen-hilman/database"
 	"backend-hanssen-hilman/models"
+	"context"
 
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
-	CreateUser(user *models.User) error
-	GetUserByID(id uint) (*models.User, error)
-	GetUserByEmail(email string) (*models.User, error)
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() UserRepository
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 }
 
 type userRepository struct {
@@ -20,22 +24,28 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) CreateUser(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) UsePrimary() UserRepository {
+	return &userRepository{db: database.Primary(r.db)}
 }
 
-func (r *userRepository) GetUserByID(id uint) (*models.User, error) {
+func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *userRepository) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	db := r.db.WithContext(ctx)
 	var user models.User
-	err := r.db.First(&user, id).Error
+	err := db.First(&user, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	db := r.db.WithContext(ctx)
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := db.Where("email = ?", email).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
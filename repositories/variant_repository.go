@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type VariantRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() VariantRepository
+	CreateVariant(ctx context.Context, variant *models.ProductVariant) error
+	GetVariant(ctx context.Context, productId, id int64) (*models.ProductVariant, error)
+	ListVariants(ctx context.Context, productId int64) ([]models.ProductVariant, error)
+	// UpdateVariant and DeleteVariant apply only while the stored version
+	// equals variant.Version, and return ErrVersionConflict otherwise.
+	UpdateVariant(ctx context.Context, variant *models.ProductVariant) error
+	// DeleteVariant fails with ErrVariantReserved while pending checkouts
+	// hold stock of the variant.
+	DeleteVariant(ctx context.Context, variant *models.ProductVariant) error
+	// SkuTaken reports whether another variant of the merchant's products,
+	// other than exceptId, already uses sku.
+	SkuTaken(ctx context.Context, merchantId int64, sku string, exceptId int64) (bool, error)
+}
+
+// ErrVariantReserved means a variant can't be removed yet because active
+// stock reservations still refer to it.
+var ErrVariantReserved = errors.New("variant has active stock reservations")
+
+type variantRepository struct {
+	db *gorm.DB
+}
+
+func NewVariantRepository(db *gorm.DB) VariantRepository {
+	return &variantRepository{db: db}
+}
+
+func (r *variantRepository) UsePrimary() VariantRepository {
+	return &variantRepository{db: database.Primary(r.db)}
+}
+
+// CreateVariant inserts the variant and records its initial stock in the
+// ledger.
+func (r *variantRepository) CreateVariant(ctx context.Context, variant *models.ProductVariant) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(variant).Error; err != nil {
+			return err
+		}
+		return recordQuantityChange(tx, variant.ProductId, &variant.Id, 0, variant.Quantity, "Initial stock")
+	})
+}
+
+func (r *variantRepository) GetVariant(ctx context.Context, productId, id int64) (*models.ProductVariant, error) {
+	var variant models.ProductVariant
+	err := r.db.WithContext(ctx).Where("id = ? AND product_id = ?", id, productId).First(&variant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+func (r *variantRepository) ListVariants(ctx context.Context, productId int64) ([]models.ProductVariant, error) {
+	var variants []models.ProductVariant
+	err := r.db.WithContext(ctx).Where("product_id = ?", productId).Order("id").Find(&variants).Error
+	return variants, err
+}
+
+func (r *variantRepository) UpdateVariant(ctx context.Context, variant *models.ProductVariant) error {
+	// Map updates bypass the JSON serializer, so the options are encoded here.
+	options, err := json.Marshal(variant.Options)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous int64
+		if err := tx.Model(&models.ProductVariant{}).Select("quantity").Where("id = ?", variant.Id).Row().Scan(&previous); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrVersionConflict
+			}
+			return err
+		}
+
+		result := tx.Model(&models.ProductVariant{}).
+			Where("id = ? AND version = ?", variant.Id, variant.Version).
+			Updates(map[string]interface{}{
+				"sku":        variant.Sku,
+				"options":    string(options),
+				"price":      variant.Price,
+				"quantity":   variant.Quantity,
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return recordQuantityChange(tx, variant.ProductId, &variant.Id, previous, variant.Quantity, "Variant update")
+	})
+	if err != nil {
+		return err
+	}
+
+	variant.Version++
+	variant.UpdatedAt = now
+	return nil
+}
+
+func (r *variantRepository) DeleteVariant(ctx context.Context, variant *models.ProductVariant) error {
+	reserved := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&models.StockReservation{}).
+			Where("variant_id = ? AND status = ?", variant.Id, models.ReservationActive)
+	}
+
+	// Checking for reservations in the same statement means a checkout
+	// can't slip in between the check and the delete.
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND version = ?", variant.Id, variant.Version).
+		Where("NOT EXISTS (?)", reserved().Select("1")).
+		Delete(&models.ProductVariant{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var active int64
+	if err := reserved().Count(&active).Error; err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrVariantReserved
+	}
+	return ErrVersionConflict
+}
+
+func (r *variantRepository) SkuTaken(ctx context.Context, merchantId int64, sku string, exceptId int64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ProductVariant{}).
+		Joins("join products on products.id = product_variants.product_id").
+		Where("products.merchant_id = ? AND product_variants.sku = ? AND product_variants.id <> ?", merchantId, sku, exceptId).
+		Count(&count).Error
+	return count > 0, err
+}
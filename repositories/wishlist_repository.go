@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"backend-hanssen-hilman/database"
+	"backend-hanssen-hilman/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WishlistRepository interface {
+	// UsePrimary returns a repository whose reads bypass the read replicas.
+	UsePrimary() WishlistRepository
+	// CreateWishlist fails with ErrWishlistNameTaken if the customer already
+	// has a list with the same name.
+	CreateWishlist(ctx context.Context, wishlist *models.Wishlist) error
+	UpdateWishlist(ctx context.Context, wishlist *models.Wishlist) error
+	DeleteWishlist(ctx context.Context, customerId, id int64) error
+	GetWishlist(ctx context.Context, id int64) (*models.Wishlist, error)
+	ListWishlists(ctx context.Context, customerId int64) ([]models.Wishlist, error)
+	// ListItems returns the list's items, oldest first. Items of deleted
+	// products are left out until the product is restored.
+	ListItems(ctx context.Context, wishlistId int64) ([]models.WishlistItem, error)
+	// SaveItem adds the item, or updates the quantity of an item already on
+	// the list. The price it was first saved at is kept.
+	SaveItem(ctx context.Context, item *models.WishlistItem) error
+	RemoveItems(ctx context.Context, wishlistId int64, ids []int64) (int64, error)
+}
+
+// ErrWishlistNameTaken means the customer already has a wishlist by that name.
+var ErrWishlistNameTaken = errors.New("wishlist name already in use")
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+func NewWishlistRepository(db *gorm.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
+
+func (r *wishlistRepository) UsePrimary() WishlistRepository {
+	return &wishlistRepository{db: database.Primary(r.db)}
+}
+
+func (r *wishlistRepository) CreateWishlist(ctx context.Context, wishlist *models.Wishlist) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkWishlistName(tx, wishlist); err != nil {
+			return err
+		}
+		return tx.Create(wishlist).Error
+	})
+}
+
+func (r *wishlistRepository) UpdateWishlist(ctx context.Context, wishlist *models.Wishlist) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkWishlistName(tx, wishlist); err != nil {
+			return err
+		}
+		result := tx.Model(&models.Wishlist{}).
+			Where("id = ? AND customer_id = ?", wishlist.Id, wishlist.CustomerId).
+			Updates(map[string]interface{}{
+				"name":       wishlist.Name,
+				"public":     wishlist.Public,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *wishlistRepository) DeleteWishlist(ctx context.Context, customerId, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND customer_id = ?", id, customerId).Delete(&models.Wishlist{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("wishlist_id = ?", id).Delete(&models.WishlistItem{}).Error
+	})
+}
+
+func (r *wishlistRepository) GetWishlist(ctx context.Context, id int64) (*models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := wishlistSummaries(r.db.WithContext(ctx)).Where("wishlists.id = ?", id).First(&wishlist).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wishlist, nil
+}
+
+func (r *wishlistRepository) ListWishlists(ctx context.Context, customerId int64) ([]models.Wishlist, error) {
+	var wishlists []models.Wishlist
+	err := wishlistSummaries(r.db.WithContext(ctx)).
+		Where("wishlists.customer_id = ?", customerId).
+		Order("wishlists.id").
+		Find(&wishlists).Error
+	return wishlists, err
+}
+
+func (r *wishlistRepository) ListItems(ctx context.Context, wishlistId int64) ([]models.WishlistItem, error) {
+	var items []models.WishlistItem
+	err := liveWishlistItems(r.db.WithContext(ctx)).
+		Where("wishlist_items.wishlist_id = ?", wishlistId).
+		Order("wishlist_items.id").
+		Find(&items).Error
+	return items, err
+}
+
+func (r *wishlistRepository) SaveItem(ctx context.Context, item *models.WishlistItem) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "wishlist_id"}, {Name: "product_id"}, {Name: "variant_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"quantity": item.Quantity, "updated_at": time.Now()}),
+	}).Create(item).Error
+}
+
+func (r *wishlistRepository) RemoveItems(ctx context.Context, wishlistId int64, ids []int64) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("wishlist_id = ? AND id IN ?", wishlistId, ids).
+		Delete(&models.WishlistItem{})
+	return result.RowsAffected, result.Error
+}
+
+func checkWishlistName(tx *gorm.DB, wishlist *models.Wishlist) error {
+	var taken int64
+	err := tx.Model(&models.Wishlist{}).
+		Where("customer_id = ? AND name = ? AND id <> ?", wishlist.CustomerId, wishlist.Name, wishlist.Id).
+		Count(&taken).Error
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrWishlistNameTaken
+	}
+	return nil
+}
+
+// wishlistSummaries selects wishlists with how many of their items are of
+// products that haven't been deleted.
+func wishlistSummaries(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Wishlist{}).
+		Select("wishlists.*, (?) as item_count",
+			liveWishlistItems(db.Session(&gorm.Session{NewDB: true})).
+				Select("COUNT(*)").
+				Where("wishlist_items.wishlist_id = wishlists.id"))
+}
+
+// liveWishlistItems selects items whose product hasn't been soft-deleted.
+func liveWishlistItems(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.WishlistItem{}).
+		Joins("join products on products.id = wishlist_items.product_id AND products.deleted_at IS NULL")
+}
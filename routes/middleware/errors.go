@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the last error a handler attached with c.Error. Errors
+// that are not an *apperror.Error are reported as INTERNAL_ERROR without
+// leaking their text. The body is RFC 7807 problem+json when problemJSON is
+// set or the client asks for it in Accept, and the JSON envelope otherwise.
+func ErrorHandler(logger *slog.Logger, problemJSON bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		var appErr *apperror.Error
+		if !errors.As(err, &appErr) {
+			appErr = apperror.Internal(err, "An unexpected error occurred")
+		}
+		if appErr.Status >= http.StatusInternalServerError {
+			logger.ErrorContext(c.Request.Context(), "request failed", "code", appErr.Code, "error", appErr.Error())
+		}
+
+		requestID := c.GetString("request_id")
+		if problemJSON || strings.Contains(c.GetHeader("Accept"), apperror.ProblemContentType) {
+			c.Render(appErr.Status, problemRender{appErr.Problem(c.Request.URL.Path, requestID)})
+			return
+		}
+		c.JSON(appErr.Status, appErr.Envelope(requestID))
+	}
+}
+
+// problemRender writes JSON with the problem+json content type.
+type problemRender struct {
+	problem apperror.Problem
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	body, err := json.Marshal(r.problem)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", apperror.ProblemContentType)
+}
@@ -1,31 +1,30 @@
 package middleware
 
 import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/logging"
 	"fmt"
-	"net/http"
-	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(secret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeUnauthorized, "Authorization header is required"))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeUnauthorized, "Authorization header format must be Bearer {token}"))
 			return
 		}
 
 		tokenString := parts[1]
-		secret := os.Getenv("JWT_SECRET")
 
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -35,16 +34,19 @@ func AuthMiddleware() gin.HandlerFunc {
 		})
 
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidToken, "Invalid token: "+err.Error()))
 			return
 		}
 
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+			userID := int64(claims["user_id"].(float64))
+			role, _ := claims["role"].(string)
 			c.Set("claims", claims)
-			c.Set("user_id", int64(claims["user_id"].(float64)))
+			c.Set("user_id", userID)
+			c.Request = c.Request.WithContext(logging.WithUser(c.Request.Context(), userID, role))
 			c.Next()
 		} else {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidToken, "Invalid token claims"))
 		}
 	}
 }
@@ -1,7 +1,7 @@
 package middleware
 
 import (
-	"net/http"
+	"backend-hanssen-hilman/apperror"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
@@ -11,19 +11,19 @@ func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claimsInterface, exists := c.Get("claims")
 		if !exists {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Claims not found in context"})
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeUnauthorized, "Claims not found in context"))
 			return
 		}
 
 		claims, ok := claimsInterface.(jwt.MapClaims)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Invalid role claims format"})
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidToken, "Invalid role claims format"))
 			return
 		}
 
 		userRole, ok := claims["role"].(string)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "User role not found or invalid type"})
+			apperror.Abort(c, apperror.Unauthorized(apperror.CodeInvalidToken, "User role not found or invalid type"))
 			return
 		}
 
@@ -34,6 +34,6 @@ func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 			}
 		}
 
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		apperror.Abort(c, apperror.Forbidden("Insufficient permissions"))
 	}
 }
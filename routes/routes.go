@@ -1,70 +1,169 @@
 package routes
 
 import (
+	"backend-hanssen-hilman/apperror"
 	"backend-hanssen-hilman/controllers"
-	"backend-hanssen-hilman/database"
-	"backend-hanssen-hilman/repositories"
 	"backend-hanssen-hilman/routes/middleware"
-	"os"
+	"backend-hanssen-hilman/storage"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes() *gin.Engine {
-	router := gin.Default()
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+// Controllers groups the handlers that SetupRoutes mounts on the router.
+type Controllers struct {
+	Metrics      http.Handler
+	Health       *controllers.HealthController
+	User         *controllers.UserController
+	Product      *controllers.ProductController
+	Transaction  *controllers.TransactionController
+	Category     *controllers.CategoryController
+	Variant      *controllers.VariantController
+	Image        *controllers.ImageController
+	Bulk         *controllers.BulkController
+	Inventory    *controllers.InventoryController
+	Notification *controllers.NotificationController
+	Review       *controllers.ReviewController
+	Wishlist     *controllers.WishlistController
+
+	// Media serves locally stored uploads; it is nil with remote storage.
+	Media http.Handler
+}
+
+func SetupRoutes(router *gin.Engine, c *Controllers, jwtSecret string) {
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(ctx *gin.Context) {
+		apperror.Abort(ctx, apperror.NotFound(apperror.CodeNotFound, "Route not found"))
+	})
+	router.NoMethod(func(ctx *gin.Context) {
+		apperror.Abort(ctx, apperror.New(http.StatusMethodNotAllowed, apperror.CodeMethodNotAllowed, "Method not allowed"))
+	})
+
+	// Health Routes
+	router.GET("/healthz", c.Health.Liveness)
+	router.GET("/readyz", c.Health.Readiness)
+	router.GET("/metrics", gin.WrapH(c.Metrics))
+	if c.Media != nil {
+		router.GET(storage.LocalMediaPath+"/*filepath", gin.WrapH(c.Media))
+	}
 
 	v1 := router.Group("/api/v1")
 
-	userController := controllers.NewUserController(repositories.NewUserRepository(database.DB))
+	authMiddleware := middleware.AuthMiddleware(jwtSecret)
 
 	// User Routes
 	userRoutes := v1.Group("/users")
 	{
-		userRoutes.POST("/login", userController.Login)
-		userRoutes.POST("/register", userController.Register)
+		userRoutes.POST("/login", c.User.Login)
+		userRoutes.POST("/register", c.User.Register)
 	}
 
 	// Product Routes
-	productController := controllers.NewProductController(repositories.NewProductRepository(database.DB))
 	productMerchantRoutes := v1.Group("/product/merchant")
-	productMerchantRoutes.Use(middleware.AuthMiddleware(), middleware.RoleMiddleware("merchant"))
+	productMerchantRoutes.Use(authMiddleware, middleware.RoleMiddleware("merchant"))
 	{
-		productMerchantRoutes.POST("/", productController.CreateProduct)
-		productMerchantRoutes.PUT("/:id", productController.UpdateProduct)
-		productMerchantRoutes.DELETE("/:id", productController.DeleteProduct)
-		productMerchantRoutes.GET("/", productController.GetProductsByMerchantID)
-		productMerchantRoutes.GET("/:id", productController.GetProductByID)
+		productMerchantRoutes.POST("/", c.Product.CreateProduct)
+		productMerchantRoutes.PUT("/:id", c.Product.UpdateProduct)
+		productMerchantRoutes.PATCH("/:id", c.Product.PatchProduct)
+		productMerchantRoutes.DELETE("/:id", c.Product.DeleteProduct)
+		productMerchantRoutes.POST("/:id/archive", c.Product.ArchiveProduct)
+		productMerchantRoutes.POST("/:id/unarchive", c.Product.UnarchiveProduct)
+		productMerchantRoutes.POST("/:id/restore", c.Product.RestoreProduct)
+		productMerchantRoutes.POST("/:id/variants", c.Variant.CreateVariant)
+		productMerchantRoutes.PUT("/:id/variants/:variantId", c.Variant.UpdateVariant)
+		productMerchantRoutes.DELETE("/:id/variants/:variantId", c.Variant.DeleteVariant)
+		productMerchantRoutes.POST("/:id/inventory", c.Inventory.AdjustStock)
+		productMerchantRoutes.GET("/:id/inventory", c.Inventory.ListMovements)
+		productMerchantRoutes.GET("/:id/reviews", c.Review.ListMerchantReviews)
+		productMerchantRoutes.PUT("/:id/reviews/:reviewId/reply", c.Review.ReplyToReview)
+		productMerchantRoutes.POST("/:id/images", c.Image.UploadImage)
+		productMerchantRoutes.PUT("/:id/images/order", c.Image.ReorderImages)
+		productMerchantRoutes.POST("/:id/images/:imageId/primary", c.Image.SetPrimaryImage)
+		productMerchantRoutes.DELETE("/:id/images/:imageId", c.Image.DeleteImage)
+		productMerchantRoutes.POST("/import", c.Bulk.ImportProducts)
+		productMerchantRoutes.GET("/imports/:jobId", c.Bulk.GetImportJob)
+		productMerchantRoutes.GET("/export", c.Bulk.ExportProducts)
+		productMerchantRoutes.GET("/", c.Product.GetProductsByMerchantID)
+		productMerchantRoutes.GET("/deleted", c.Product.ListDeletedProducts)
+		productMerchantRoutes.GET("/:id", c.Product.GetProductByID)
 	}
 
 	productRoutes := v1.Group("/products")
-	productRoutes.Use(middleware.AuthMiddleware(), middleware.RoleMiddleware("customer"))
+	productRoutes.Use(authMiddleware, middleware.RoleMiddleware("customer"))
 	{
-		productRoutes.GET("/", productController.ListProducts)
-		productRoutes.GET("/:id", productController.GetProductByID)
+		productRoutes.GET("/", c.Product.ListProducts)
+		productRoutes.GET("/:id", c.Product.GetProductByID)
+		productRoutes.POST("/:id/subscription", c.Notification.SubscribeBackInStock)
+		productRoutes.DELETE("/:id/subscription", c.Notification.UnsubscribeBackInStock)
+		productRoutes.GET("/:id/reviews", c.Review.ListReviews)
+		productRoutes.POST("/:id/reviews", c.Review.CreateReview)
+		productRoutes.PUT("/:id/reviews/:reviewId", c.Review.UpdateReview)
+		productRoutes.DELETE("/:id/reviews/:reviewId", c.Review.DeleteReview)
 	}
 
-	transactionController := controllers.NewTransactionController(repositories.NewTransactionRepository(database.DB), repositories.NewProductRepository(database.DB))
+	// Wishlist Routes
+	wishlistRoutes := v1.Group("/wishlists")
+	wishlistRoutes.Use(authMiddleware, middleware.RoleMiddleware("customer"))
+	{
+		wishlistRoutes.GET("/", c.Wishlist.ListWishlists)
+		wishlistRoutes.POST("/", c.Wishlist.CreateWishlist)
+		wishlistRoutes.GET("/:id", c.Wishlist.GetWishlist)
+		wishlistRoutes.PUT("/:id", c.Wishlist.UpdateWishlist)
+		wishlistRoutes.DELETE("/:id", c.Wishlist.DeleteWishlist)
+		wishlistRoutes.POST("/:id/items", c.Wishlist.AddItem)
+		wishlistRoutes.DELETE("/:id/items/:itemId", c.Wishlist.RemoveItem)
+		wishlistRoutes.POST("/:id/purchase", c.Wishlist.PurchaseItems)
+	}
+
+	// Notification Routes
+	notificationRoutes := v1.Group("/notifications")
+	notificationRoutes.Use(authMiddleware)
+	{
+		notificationRoutes.GET("/", c.Notification.ListNotifications)
+		notificationRoutes.POST("/read", c.Notification.MarkAllRead)
+		notificationRoutes.POST("/:id/read", c.Notification.MarkRead)
+		notificationRoutes.GET("/preferences", c.Notification.GetPreferences)
+		notificationRoutes.PUT("/preferences", c.Notification.UpdatePreferences)
+	}
+
+	// Review Routes
+	v1.POST("/reviews/:id/flags", authMiddleware, c.Review.FlagReview)
+
+	adminReviewRoutes := v1.Group("/admin/reviews")
+	adminReviewRoutes.Use(authMiddleware, middleware.RoleMiddleware("admin"))
+	{
+		adminReviewRoutes.GET("/flagged", c.Review.ListFlaggedReviews)
+		adminReviewRoutes.POST("/:id/moderate", c.Review.ModerateReview)
+	}
+
+	// Category Routes
+	v1.GET("/categories", c.Category.ListCategories)
+
+	adminCategoryRoutes := v1.Group("/admin/categories")
+	adminCategoryRoutes.Use(authMiddleware, middleware.RoleMiddleware("admin"))
+	{
+		adminCategoryRoutes.POST("/", c.Category.CreateCategory)
+		adminCategoryRoutes.PUT("/:id", c.Category.UpdateCategory)
+		adminCategoryRoutes.DELETE("/:id", c.Category.DeleteCategory)
+	}
 
 	// Merchant Routes
 	merchantTransactionRoutes := v1.Group("/transactions/merchant")
-	merchantTransactionRoutes.Use(middleware.AuthMiddleware(), middleware.RoleMiddleware("merchant"))
+	merchantTransactionRoutes.Use(authMiddleware, middleware.RoleMiddleware("merchant"))
 	{
-		merchantTransactionRoutes.GET("/:id", transactionController.GetTransactionByID)
-		merchantTransactionRoutes.GET("/", transactionController.ListTransactionsByMerchantID)
+		merchantTransactionRoutes.GET("/:id", c.Transaction.GetTransactionByID)
+		merchantTransactionRoutes.POST("/:id/refund", c.Transaction.RefundTransaction)
+		merchantTransactionRoutes.GET("/", c.Transaction.ListTransactionsByMerchantID)
 	}
 
 	// Customer Routes
 	customerTransactionRoutes := v1.Group("/transactions/customer")
-	customerTransactionRoutes.Use(middleware.AuthMiddleware(), middleware.RoleMiddleware("customer"))
+	customerTransactionRoutes.Use(authMiddleware, middleware.RoleMiddleware("customer"))
 	{
-		customerTransactionRoutes.POST("/", transactionController.CreateTransaction)
-		customerTransactionRoutes.GET("/", transactionController.ListTransactionsByCustomerID)
-		customerTransactionRoutes.GET("/:id", transactionController.GetTransactionByID)
+		customerTransactionRoutes.POST("/", c.Transaction.CreateTransaction)
+		customerTransactionRoutes.GET("/", c.Transaction.ListTransactionsByCustomerID)
+		customerTransactionRoutes.GET("/:id", c.Transaction.GetTransactionByID)
+		customerTransactionRoutes.POST("/:id/pay", c.Transaction.PayTransaction)
+		customerTransactionRoutes.POST("/:id/cancel", c.Transaction.CancelTransaction)
 	}
-
-	// Run the server
-	router.Run(":" + os.Getenv("PORT"))
-	return router
 }
@@ -0,0 +1,293 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Weights of the ways a query word can match an indexed word, and of the two
+// searchable fields.
+const (
+	exactWeight  = 1.0
+	prefixWeight = 0.7
+	typoWeight   = 0.5
+
+	nameWeight        = 2.0
+	descriptionWeight = 1.0
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type posting struct {
+	name        int
+	description int
+}
+
+type docStats struct {
+	nameLen        int
+	descriptionLen int
+	terms          []string
+}
+
+// InvertedIndex is an in-memory SearchIndex that ranks documents with BM25
+// over the name and description. It works with every database driver but
+// only sees changes made through this process, so it should be rebuilt
+// periodically when several instances share a database.
+type InvertedIndex struct {
+	mu                  sync.RWMutex
+	postings            map[string]map[int64]posting
+	docs                map[int64]docStats
+	vocabulary          []string // sorted keys of postings, for prefix lookups
+	totalNameLen        int
+	totalDescriptionLen int
+}
+
+func NewInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{
+		postings: make(map[string]map[int64]posting),
+		docs:     make(map[int64]docStats),
+	}
+}
+
+func (idx *InvertedIndex) Index(doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(doc.ID)
+	idx.add(doc)
+}
+
+func (idx *InvertedIndex) Remove(id int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *InvertedIndex) Reset(docs []Document) {
+	fresh := NewInvertedIndex()
+	for _, doc := range docs {
+		fresh.add(doc)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.postings = fresh.postings
+	idx.docs = fresh.docs
+	idx.vocabulary = fresh.vocabulary
+	idx.totalNameLen = fresh.totalNameLen
+	idx.totalDescriptionLen = fresh.totalDescriptionLen
+}
+
+func (idx *InvertedIndex) add(doc Document) {
+	nameTerms := Tokenize(doc.Name)
+	descriptionTerms := Tokenize(doc.Description)
+
+	counts := make(map[string]posting)
+	for _, term := range nameTerms {
+		p := counts[term]
+		p.name++
+		counts[term] = p
+	}
+	for _, term := range descriptionTerms {
+		p := counts[term]
+		p.description++
+		counts[term] = p
+	}
+
+	stats := docStats{nameLen: len(nameTerms), descriptionLen: len(descriptionTerms)}
+	for term, p := range counts {
+		docs, ok := idx.postings[term]
+		if !ok {
+			docs = make(map[int64]posting)
+			idx.postings[term] = docs
+			i, _ := slices.BinarySearch(idx.vocabulary, term)
+			idx.vocabulary = slices.Insert(idx.vocabulary, i, term)
+		}
+		docs[doc.ID] = p
+		stats.terms = append(stats.terms, term)
+	}
+	idx.docs[doc.ID] = stats
+	idx.totalNameLen += stats.nameLen
+	idx.totalDescriptionLen += stats.descriptionLen
+}
+
+func (idx *InvertedIndex) remove(id int64) {
+	stats, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range stats.terms {
+		docs := idx.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+			if i, found := slices.BinarySearch(idx.vocabulary, term); found {
+				idx.vocabulary = slices.Delete(idx.vocabulary, i, i+1)
+			}
+		}
+	}
+	delete(idx.docs, id)
+	idx.totalNameLen -= stats.nameLen
+	idx.totalDescriptionLen -= stats.descriptionLen
+}
+
+func (idx *InvertedIndex) Search(query string, limit int) []Hit {
+	words := Tokenize(query)
+	if len(words) == 0 || limit <= 0 {
+		return nil
+	}
+	words = slices.Compact(slices.Sorted(slices.Values(words)))
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if len(idx.docs) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.docs))
+	avgName := math.Max(float64(idx.totalNameLen)/n, 1)
+	avgDescription := math.Max(float64(idx.totalDescriptionLen)/n, 1)
+
+	type match struct {
+		score float64
+		words int
+		terms []string
+	}
+	matches := make(map[int64]*match)
+	for _, word := range words {
+		// A document scores through its best expansion of each query word.
+		best := make(map[int64]float64)
+		bestTerm := make(map[int64]string)
+		for term, weight := range idx.expand(word) {
+			docs := idx.postings[term]
+			idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+			for id, p := range docs {
+				stats := idx.docs[id]
+				tf := nameWeight*bm25TF(p.name, stats.nameLen, avgName) +
+					descriptionWeight*bm25TF(p.description, stats.descriptionLen, avgDescription)
+				score := weight * idf * tf
+				if score > best[id] {
+					best[id] = score
+					bestTerm[id] = term
+				}
+			}
+		}
+		for id, score := range best {
+			m := matches[id]
+			if m == nil {
+				m = &match{}
+				matches[id] = m
+			}
+			m.score += score
+			m.words++
+			m.terms = append(m.terms, bestTerm[id])
+		}
+	}
+
+	hits := make([]Hit, 0, len(matches))
+	for id, m := range matches {
+		if m.words == len(words) {
+			hits = append(hits, Hit{ID: id, Score: m.score, Terms: m.terms})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// expand returns the indexed terms a query word matches with their weights:
+// the word itself, words it is a prefix of, and words within a small edit
+// distance. Short words get no typo tolerance as it would match too much.
+func (idx *InvertedIndex) expand(word string) map[string]float64 {
+	terms := make(map[string]float64)
+	if _, ok := idx.postings[word]; ok {
+		terms[word] = exactWeight
+	}
+
+	if len([]rune(word)) >= 2 {
+		i, _ := slices.BinarySearch(idx.vocabulary, word)
+		for ; i < len(idx.vocabulary) && strings.HasPrefix(idx.vocabulary[i], word); i++ {
+			if idx.vocabulary[i] != word {
+				terms[idx.vocabulary[i]] = prefixWeight
+			}
+		}
+	}
+
+	maxEdits := typoTolerance(word)
+	if maxEdits == 0 {
+		return terms
+	}
+	for _, term := range idx.vocabulary {
+		if _, ok := terms[term]; ok {
+			continue
+		}
+		if levenshtein(word, term, maxEdits) <= maxEdits {
+			terms[term] = typoWeight
+		}
+	}
+	return terms
+}
+
+func typoTolerance(word string) int {
+	switch n := len([]rune(word)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func bm25TF(freq, length int, avgLength float64) float64 {
+	if freq == 0 {
+		return 0
+	}
+	f := float64(freq)
+	return f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(length)/avgLength))
+}
+
+// levenshtein returns the edit distance between a and b, or limit+1 once it is
+// known to exceed limit.
+func levenshtein(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func newTestIndex() *InvertedIndex {
+	idx := NewInvertedIndex()
+	for _, doc := range []Document{
+		{ID: 1, Name: "Red Cotton Shirt", Description: "A soft tee"},
+		{ID: 2, Name: "Denim Jacket", Description: "Classic denim with a cotton lining"},
+		{ID: 3, Name: "Cotton Socks", Description: "Pack of three"},
+		{ID: 4, Name: "Leather Boots", Description: "Waterproof and warm"},
+		{ID: 5, Name: "Boot Polish", Description: "Keeps leather supple"},
+		{ID: 6, Name: "Shirt Dress", Description: "A long shirt worn as a dress"},
+	} {
+		idx.Index(doc)
+	}
+	return idx
+}
+
+func hitIDs(hits []Hit) []int64 {
+	ids := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestInvertedIndexSearch(t *testing.T) {
+	idx := newTestIndex()
+	tests := []struct {
+		name  string
+		query string
+		want  []int64
+	}{
+		// Name matches outrank description matches, and shorter fields and
+		// repeated words rank higher.
+		{"name before description", "cotton", []int64{3, 1, 2}},
+		{"repeated word", "shirt", []int64{6, 1}},
+		{"exact before prefix", "boot", []int64{5, 4}},
+		{"every word must match", "cotton shirt", []int64{1}},
+		{"case and punctuation", "LEATHER, Waterproof!", []int64{4}},
+		{"prefix", "cott", []int64{3, 1, 2}},
+		{"typo", "leathr", []int64{4, 5}},
+		{"two typos in a long word", "watreproof", []int64{4}},
+		{"no typos in short words", "sok", nil},
+		{"no match", "hat", nil},
+		{"no words", " !? ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hitIDs(idx.Search(tt.query, 10)); !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	if got := hitIDs(idx.Search("cotton", 2)); !slices.Equal(got, []int64{3, 1}) {
+		t.Errorf("Search with limit 2 = %v, want the best two", got)
+	}
+	if hits := idx.Search("leathr", 10); !slices.Equal(hits[0].Terms, []string{"leather"}) {
+		t.Errorf("typo hit terms = %v, want the indexed word", hits[0].Terms)
+	}
+}
+
+func TestInvertedIndexUpdates(t *testing.T) {
+	idx := newTestIndex()
+
+	idx.Remove(1)
+	if got := hitIDs(idx.Search("shirt", 10)); !slices.Equal(got, []int64{6}) {
+		t.Errorf("after Remove(1), shirt = %v, want [6]", got)
+	}
+	if got := idx.Search("red", 10); len(got) != 0 {
+		t.Errorf("after Remove(1), red = %v, want nothing", hitIDs(got))
+	}
+
+	// Reindexing replaces the old words rather than adding to them.
+	idx.Index(Document{ID: 3, Name: "Wool Socks"})
+	if got := hitIDs(idx.Search("cotton", 10)); !slices.Equal(got, []int64{2}) {
+		t.Errorf("after reindexing 3, cotton = %v, want [2]", got)
+	}
+	if got := hitIDs(idx.Search("wool", 10)); !slices.Equal(got, []int64{3}) {
+		t.Errorf("after reindexing 3, wool = %v, want [3]", got)
+	}
+
+	idx.Reset([]Document{{ID: 7, Name: "Straw Hat"}})
+	if got := idx.Search("shirt", 10); len(got) != 0 {
+		t.Errorf("after Reset, shirt = %v, want nothing", hitIDs(got))
+	}
+	if got := hitIDs(idx.Search("hat", 10)); !slices.Equal(got, []int64{7}) {
+		t.Errorf("after Reset, hat = %v, want [7]", got)
+	}
+}
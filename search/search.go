@@ -0,0 +1,118 @@
+// Package search ranks products by how well their name and description match
+// a free-text query.
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Document is the searchable text of one product.
+type Document struct {
+	ID          int64
+	Name        string
+	Description string
+}
+
+// Hit is a matching document. Terms are the indexed words that matched, after
+// prefix and typo expansion, for highlighting.
+type Hit struct {
+	ID    int64
+	Score float64
+	Terms []string
+}
+
+// SearchIndex is a full-text index over product documents. Implementations
+// must be safe for concurrent use.
+type SearchIndex interface {
+	// Index adds the document or replaces an earlier version of it.
+	Index(doc Document)
+	Remove(id int64)
+	// Reset replaces the whole index with docs.
+	Reset(docs []Document)
+	// Search returns at most limit hits, best first. Every query word must
+	// match a word of the document, exactly, as a prefix or with a typo.
+	Search(query string, limit int) []Hit
+}
+
+// Tokenize splits text into lower-case words of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Snippet returns an HTML-escaped excerpt of text of about maxRunes runes
+// around the first matching word, with every word in terms wrapped in
+// <mark>. Text without a match yields "".
+func Snippet(text string, terms []string, maxRunes int) string {
+	matches := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		matches[term] = true
+	}
+
+	type word struct{ start, end int }
+	var words []word
+	runes := []rune(text)
+	first := -1
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(runes) && isWordRune(runes[i]) {
+			i++
+		}
+		if first < 0 && matches[strings.ToLower(string(runes[start:i]))] {
+			first = len(words)
+		}
+		words = append(words, word{start, i})
+	}
+	if first < 0 {
+		return ""
+	}
+
+	// Start a little before the first match so it has some context, unless
+	// the whole text fits.
+	from := 0
+	if len(runes) > maxRunes {
+		from = max(0, words[first].start-maxRunes/4)
+	}
+	for from > 0 && isWordRune(runes[from-1]) {
+		from--
+	}
+	to := min(len(runes), from+maxRunes)
+	for to < len(runes) && isWordRune(runes[to]) {
+		to++
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, w := range words {
+		if w.end <= from || w.start >= to {
+			continue
+		}
+		if !matches[strings.ToLower(string(runes[w.start:w.end]))] {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:w.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[w.start:w.end])))
+		b.WriteString("</mark>")
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
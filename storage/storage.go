@@ -0,0 +1,88 @@
+// Package storage keeps uploaded files such as product images outside the
+// database, behind the BlobStore interface.
+package storage
+
+import (
+	"backend-hanssen-hilman/util"
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Supported values for Config.Backend.
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// BlobStore saves and removes blobs by key and tells clients where to fetch
+// them. Keys are slash-separated relative paths chosen by the caller.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL is the address clients download the blob from.
+	URL(key string) string
+}
+
+// Config represents blob storage configuration
+type Config struct {
+	Backend string
+	// PublicURL is prepended to keys to build download URLs. For the local
+	// backend it defaults to the /media route served by the app.
+	PublicURL string
+
+	LocalDir string
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+
+	// MaxImageBytes bounds a single uploaded image, and ThumbnailSize is the
+	// longest side of generated thumbnails in pixels.
+	MaxImageBytes int64
+	ThumbnailSize int
+}
+
+// BuildConfig to set value of Config
+func BuildConfig() *Config {
+	storageConfig := Config{
+		Backend:       os.Getenv("STORAGE_BACKEND"),
+		PublicURL:     os.Getenv("STORAGE_PUBLIC_URL"),
+		LocalDir:      os.Getenv("STORAGE_LOCAL_DIR"),
+		S3Endpoint:    os.Getenv("S3_ENDPOINT"),
+		S3Region:      os.Getenv("S3_REGION"),
+		S3Bucket:      os.Getenv("S3_BUCKET"),
+		S3AccessKey:   os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:   os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:      os.Getenv("S3_USE_SSL") != "false",
+		MaxImageBytes: int64(util.GetEnvInt("IMAGE_MAX_BYTES", 5<<20)),
+		ThumbnailSize: util.GetEnvInt("IMAGE_THUMBNAIL_SIZE", 320),
+	}
+	if storageConfig.Backend == "" {
+		storageConfig.Backend = BackendLocal
+	}
+	if storageConfig.LocalDir == "" {
+		storageConfig.LocalDir = "uploads"
+	}
+	return &storageConfig
+}
+
+// New opens the configured backend.
+func New(cfg *Config) (BlobStore, error) {
+	switch cfg.Backend {
+	case BackendLocal:
+		publicURL := cfg.PublicURL
+		if publicURL == "" {
+			publicURL = LocalMediaPath
+		}
+		return NewLocalStore(cfg.LocalDir, publicURL)
+	case BackendS3:
+		return NewS3Store(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
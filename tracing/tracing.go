@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"backend-hanssen-hilman/util"
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported values for Config.Exporter.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Config represents tracing configuration
+type Config struct {
+	ServiceName string
+	// Exporter selects where finished spans go. With ExporterNone spans are
+	// still created, so trace IDs propagate and show up in logs.
+	Exporter string
+	// OTLPEndpoint is the collector's host:port. When empty the exporter
+	// falls back to the standard OTEL_EXPORTER_OTLP_* variables.
+	OTLPEndpoint string
+	OTLPInsecure bool
+	SampleRatio  float64
+
+	// SpanExporter, when set, takes precedence over Exporter. Tests use it to
+	// collect spans in memory with tracetest.NewInMemoryExporter.
+	SpanExporter sdktrace.SpanExporter
+}
+
+// BuildConfig to set value of Config
+func BuildConfig() *Config {
+	tracingConfig := Config{
+		ServiceName:  os.Getenv("OTEL_SERVICE_NAME"),
+		Exporter:     os.Getenv("TRACING_EXPORTER"),
+		OTLPEndpoint: os.Getenv("TRACING_OTLP_ENDPOINT"),
+		OTLPInsecure: os.Getenv("TRACING_OTLP_INSECURE") == "true",
+		SampleRatio:  util.GetEnvFloat("TRACING_SAMPLE_RATIO", 1),
+	}
+	if tracingConfig.ServiceName == "" {
+		tracingConfig.ServiceName = "backend-hanssen-hilman"
+	}
+	if tracingConfig.Exporter == "" {
+		tracingConfig.Exporter = ExporterNone
+	}
+	return &tracingConfig
+}
+
+// Tracing owns the tracer provider and the propagator used for incoming
+// requests. It does not touch the OpenTelemetry globals.
+type Tracing struct {
+	serviceName string
+	Provider    *sdktrace.TracerProvider
+	Propagator  propagation.TextMapPropagator
+}
+
+// New builds a tracer provider that exports through the configured exporter.
+func New(ctx context.Context, tracingConfig *Config) (*Tracing, error) {
+	exporter, err := newExporter(ctx, tracingConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(tracingConfig.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tracingConfig.SampleRatio))),
+	}
+	switch {
+	case tracingConfig.SpanExporter != nil || tracingConfig.Exporter == ExporterStdout:
+		// Export synchronously so spans are visible as soon as they end.
+		options = append(options, sdktrace.WithSyncer(exporter))
+	case exporter != nil:
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	return &Tracing{
+		serviceName: tracingConfig.ServiceName,
+		Provider:    sdktrace.NewTracerProvider(options...),
+		Propagator:  propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}, nil
+}
+
+func newExporter(ctx context.Context, tracingConfig *Config) (sdktrace.SpanExporter, error) {
+	if tracingConfig.SpanExporter != nil {
+		return tracingConfig.SpanExporter, nil
+	}
+
+	switch tracingConfig.Exporter {
+	case ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if tracingConfig.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(tracingConfig.OTLPEndpoint))
+		}
+		if tracingConfig.OTLPInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter %q", tracingConfig.Exporter)
+	}
+}
+
+// Tracer returns a named tracer from the provider.
+func (t *Tracing) Tracer(name string) trace.Tracer {
+	return t.Provider.Tracer(name)
+}
+
+// Middleware starts a server span per request, continuing the trace from
+// incoming W3C traceparent headers.
+func (t *Tracing) Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(t.serviceName,
+		otelgin.WithTracerProvider(t.Provider),
+		otelgin.WithPropagators(t.Propagator),
+	)
+}
+
+// Shutdown flushes pending spans and stops the exporter.
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	return t.Provider.Shutdown(ctx)
+}
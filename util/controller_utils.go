@@ -2,6 +2,10 @@ package util
 
 import (
 	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 // PaginationParams defines the structure for pagination query parameters.
@@ -10,7 +14,11 @@ type PaginationParams struct {
 	Limit int `form:"limit"`
 }
 
+// MaxPageSize caps the limit of every listing.
+const MaxPageSize = 100
+
 // SetPaginationDefaults validates and sets default values for page and limit.
+// Limits above MaxPageSize are lowered to it.
 func SetPaginationDefaults(page, limit int) (int, int) {
 	if page <= 0 {
 		page = 1
@@ -18,9 +26,23 @@ func SetPaginationDefaults(page, limit int) (int, int) {
 	if limit <= 0 {
 		limit = 10 // Default page size
 	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
 	return page, limit
 }
 
+// PageLink returns the current request's path and query with the cursor
+// parameter set to token, replacing any page number.
+func PageLink(ctx *gin.Context, token string) string {
+	u := *ctx.Request.URL
+	query := u.Query()
+	query.Del("page")
+	query.Set("cursor", token)
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
 // CalculateTotalPages computes the total number of pages for a paginated response.
 func CalculateTotalPages(totalRecords int64, limit int) int {
 	if totalRecords == 0 {
@@ -28,3 +50,27 @@ func CalculateTotalPages(totalRecords int64, limit int) int {
 	}
 	return int(math.Ceil(float64(totalRecords) / float64(limit)))
 }
+
+// readYourWritesCookie marks a client that wrote recently, so its follow-up
+// reads are served by the primary instead of a possibly lagging replica.
+const readYourWritesCookie = "read_your_writes"
+
+// MarkWrite pins the caller's reads to the primary for window after a write.
+func MarkWrite(ctx *gin.Context, window time.Duration) {
+	ctx.Set(readYourWritesCookie, true)
+	if window > 0 {
+		ctx.SetSameSite(http.SameSiteLaxMode)
+		ctx.SetCookie(readYourWritesCookie, "1", int(math.Ceil(window.Seconds())), "/", "", false, true)
+	}
+}
+
+// PrefersPrimary reports whether reads for this request must see the
+// caller's own writes: the request itself wrote, the client asked for it with
+// the X-Read-Your-Writes header, or a recent write left the cookie behind.
+func PrefersPrimary(ctx *gin.Context) bool {
+	if ctx.GetBool(readYourWritesCookie) || ctx.GetHeader("X-Read-Your-Writes") != "" {
+		return true
+	}
+	_, err := ctx.Cookie(readYourWritesCookie)
+	return err == nil
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ETag builds a strong entity tag for a resource of the given kind, such as
+// "product", from its ID and version column. Parts fold in state that the
+// representation includes but the version doesn't track, such as embedded
+// child resources.
+func ETag(kind string, id, version int64, parts ...any) string {
+	tag := kind + "-" + strconv.FormatInt(id, 10) + "-" + strconv.FormatInt(version, 10)
+	if len(parts) > 0 {
+		h := sha256.New()
+		for _, part := range parts {
+			fmt.Fprint(h, part, "|")
+		}
+		tag += "-" + hex.EncodeToString(h.Sum(nil)[:8])
+	}
+	return `"` + tag + `"`
+}
+
+// ETagMatchesWeak reports whether an If-None-Match header lists etag or is
+// "*". It uses the weak comparison, so W/ tags match their strong form.
+func ETagMatchesWeak(header, etag string) bool {
+	return etagListed(header, func(candidate string) bool {
+		return strings.TrimPrefix(candidate, "W/") == etag
+	})
+}
+
+// ETagMatchesStrong reports whether an If-Match header lists etag or is "*".
+// It uses the strong comparison RFC 7232 requires for If-Match, so a weak tag
+// never matches.
+func ETagMatchesStrong(header, etag string) bool {
+	return etagListed(header, func(candidate string) bool {
+		return candidate == etag
+	})
+}
+
+func etagListed(header string, matches func(candidate string) bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || matches(candidate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package util
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	etag := ETag("product", 1, 2)
+	tests := []struct {
+		header       string
+		weak, strong bool
+	}{
+		{`"product-1-2"`, true, true},
+		{`W/"product-1-2"`, true, false},
+		{`"product-1-1", "product-1-2"`, true, true},
+		{`"variant-1-2"`, false, false},
+		{`*`, true, true},
+		{`"product-1-3"`, false, false},
+	}
+	for _, tt := range tests {
+		if got := ETagMatchesWeak(tt.header, etag); got != tt.weak {
+			t.Errorf("ETagMatchesWeak(%s) = %v, want %v", tt.header, got, tt.weak)
+		}
+		if got := ETagMatchesStrong(tt.header, etag); got != tt.strong {
+			t.Errorf("ETagMatchesStrong(%s) = %v, want %v", tt.header, got, tt.strong)
+		}
+	}
+}
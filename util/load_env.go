@@ -1,7 +1,10 @@
 package util
 
 import (
-	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -9,6 +12,36 @@ import (
 func LoadEnv() {
 	err := godotenv.Load(".env")
 	if err != nil {
-		fmt.Println("Error loading .env file")
+		slog.Warn("Error loading .env file", "error", err)
 	}
 }
+
+// GetEnvInt reads an integer environment variable, falling back to def when
+// it is unset or malformed.
+func GetEnvInt(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// GetEnvFloat reads a floating point environment variable, falling back to
+// def when it is unset or malformed.
+func GetEnvFloat(key string, def float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// GetEnvDuration reads a duration such as "30s" or "5m" from the environment,
+// falling back to def when it is unset or malformed.
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
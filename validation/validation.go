@@ -0,0 +1,130 @@
+package validation
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// MaxMoney is the largest price or amount accepted from clients.
+	MaxMoney = 1_000_000_000
+
+	PasswordMinLength = 8
+	// PasswordMaxLength is bcrypt's input limit; longer passwords would be
+	// silently truncated.
+	PasswordMaxLength = 72
+)
+
+var registerOnce sync.Once
+var registerErr error
+
+// Setup registers the custom rules and JSON field naming on gin's validator.
+// It is safe to call more than once.
+func Setup() error {
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		registerErr = Register(v)
+	})
+	return registerErr
+}
+
+// Register adds the custom rules to v:
+//
+//	notblank  string contains something other than whitespace
+//	password  8-72 characters with upper case, lower case and a digit
+//	money     0 <= amount <= MaxMoney with at most two decimal places
+//	slug      lower case words of letters and digits joined by single hyphens
+func Register(v *validator.Validate) error {
+	v.RegisterTagNameFunc(fieldName)
+
+	rules := map[string]validator.Func{
+		"notblank": notBlank,
+		"password": password,
+		"money":    money,
+		"slug":     slug,
+	}
+	for tag, fn := range rules {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldName reports fields by their json (or, for query structs, form) name
+// so error details match what the client sent.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func notBlank(fl validator.FieldLevel) bool {
+	return strings.TrimSpace(fl.Field().String()) != ""
+}
+
+func password(fl validator.FieldLevel) bool {
+	return IsPassword(fl.Field().String())
+}
+
+// IsPassword reports whether value passes the password rule.
+func IsPassword(value string) bool {
+	if len(value) < PasswordMinLength || len(value) > PasswordMaxLength {
+		return false
+	}
+
+	var upper, lower, digit bool
+	for _, r := range value {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func slug(fl validator.FieldLevel) bool {
+	return slugPattern.MatchString(fl.Field().String())
+}
+
+func money(fl validator.FieldLevel) bool {
+	var amount float64
+	switch fl.Field().Kind() {
+	case reflect.Float32, reflect.Float64:
+		amount = fl.Field().Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		amount = float64(fl.Field().Int())
+	default:
+		return false
+	}
+
+	if math.IsNaN(amount) || amount < 0 || amount > MaxMoney {
+		return false
+	}
+	// Allow for binary floating point noise when checking the cents.
+	cents := amount * 100
+	return math.Abs(cents-math.Round(cents)) < 1e-6
+}
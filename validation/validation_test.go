@@ -0,0 +1,248 @@
+package validation
+
+import (
+	"backend-hanssen-hilman/apperror"
+	"backend-hanssen-hilman/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func newValidator(t *testing.T) *validator.Validate {
+	t.Helper()
+	v := validator.New()
+	v.SetTagName("binding")
+	if err := Register(v); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return v
+}
+
+func TestRules(t *testing.T) {
+	v := newValidator(t)
+	tests := []struct {
+		rule  string
+		value any
+		valid bool
+	}{
+		{"notblank", "shirt", true},
+		{"notblank", " a ", true},
+		{"notblank", "", false},
+		{"notblank", " \t\n", false},
+
+		{"password", "Passw0rd", true},
+		{"password", "Passw0rd" + strings.Repeat("x", PasswordMaxLength-8), true},
+		{"password", "Pa55w0r", false},
+		{"password", "Passw0rd" + strings.Repeat("x", PasswordMaxLength-7), false},
+		{"password", "passw0rd", false},
+		{"password", "PASSW0RD", false},
+		{"password", "Password", false},
+
+		{"money", 0.0, true},
+		{"money", 19.99, true},
+		{"money", 0.1 + 0.2, true},
+		{"money", float64(MaxMoney), true},
+		{"money", int64(500), true},
+		{"money", 19.999, false},
+		{"money", -0.01, false},
+		{"money", float64(MaxMoney) + 0.01, false},
+		{"money", "10", false},
+
+		{"slug", "mens-shirts", true},
+		{"slug", "a1", true},
+		{"slug", "Mens-Shirts", false},
+		{"slug", "mens--shirts", false},
+		{"slug", "-mens", false},
+		{"slug", "mens-", false},
+		{"slug", "mens_shirts", false},
+		{"slug", "", false},
+	}
+	for _, tt := range tests {
+		err := v.Var(tt.value, tt.rule)
+		if got := err == nil; got != tt.valid {
+			t.Errorf("%s(%#v) valid = %v, want %v (err: %v)", tt.rule, tt.value, got, tt.valid, err)
+		}
+	}
+}
+
+type testItem struct {
+	Quantity int64 `json:"quantity" binding:"gt=0"`
+}
+
+type testRequest struct {
+	Name     string     `json:"name" binding:"required,notblank,max=10"`
+	Slug     string     `json:"slug" binding:"omitempty,slug"`
+	Password string     `json:"password" binding:"omitempty,password"`
+	Price    float64    `json:"price" binding:"money"`
+	Role     string     `json:"role" binding:"omitempty,oneof=customer merchant"`
+	MinPrice float64    `form:"min_price"`
+	MaxPrice float64    `form:"max_price" binding:"omitempty,gtefield=MinPrice"`
+	Items    []testItem `json:"items" binding:"dive"`
+}
+
+func TestErrorBody(t *testing.T) {
+	v := newValidator(t)
+	valid := func() testRequest { return testRequest{Name: "shirt", Price: 10} }
+	tests := []struct {
+		name   string
+		modify func(*testRequest)
+		want   []apperror.FieldError
+	}{
+		{"required", func(r *testRequest) { r.Name = "" },
+			[]apperror.FieldError{{Field: "name", Rule: "required", Message: "is required"}}},
+		{"notblank", func(r *testRequest) { r.Name = "   " },
+			[]apperror.FieldError{{Field: "name", Rule: "notblank", Message: "must not be blank"}}},
+		{"max", func(r *testRequest) { r.Name = "a very long name" },
+			[]apperror.FieldError{{Field: "name", Rule: "max", Message: "must be at most 10"}}},
+		{"slug", func(r *testRequest) { r.Slug = "Not A Slug" },
+			[]apperror.FieldError{{Field: "slug", Rule: "slug", Message: "must contain only lower case letters, digits and single hyphens"}}},
+		{"password", func(r *testRequest) { r.Password = "password" },
+			[]apperror.FieldError{{Field: "password", Rule: "password", Message: "must be 8 to 72 characters long and contain an upper case letter, a lower case letter and a digit"}}},
+		{"money", func(r *testRequest) { r.Price = 1.005 },
+			[]apperror.FieldError{{Field: "price", Rule: "money", Message: "must be an amount between 0 and 1000000000 with at most two decimal places"}}},
+		{"oneof", func(r *testRequest) { r.Role = "admin" },
+			[]apperror.FieldError{{Field: "role", Rule: "oneof", Message: "must be one of: customer merchant"}}},
+		{"gtefield", func(r *testRequest) { r.MinPrice, r.MaxPrice = 20, 10 },
+			[]apperror.FieldError{{Field: "max_price", Rule: "gtefield", Message: "must be greater than or equal to min_price"}}},
+		{"nested", func(r *testRequest) { r.Items = []testItem{{Quantity: 1}, {Quantity: 0}} },
+			[]apperror.FieldError{{Field: "items[1].quantity", Rule: "gt", Message: "must be greater than 0"}}},
+		{"several", func(r *testRequest) { r.Name, r.Price = "", -1 },
+			[]apperror.FieldError{
+				{Field: "name", Rule: "required", Message: "is required"},
+				{Field: "price", Rule: "money", Message: "must be an amount between 0 and 1000000000 with at most two decimal places"},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			err := v.Struct(req)
+			if err == nil {
+				t.Fatal("request passed validation")
+			}
+
+			appErr := apperror.Validation(err)
+			want := apperror.Envelope{Error: apperror.Body{
+				Code:    apperror.CodeValidationFailed,
+				Message: "The request is invalid",
+				Details: tt.want,
+			}}
+			got, _ := json.Marshal(appErr.Envelope(""))
+			wantJSON, _ := json.Marshal(want)
+			if appErr.Status != http.StatusBadRequest || string(got) != string(wantJSON) {
+				t.Errorf("got %d %s\nwant 400 %s", appErr.Status, got, wantJSON)
+			}
+		})
+	}
+
+	if err := v.Struct(valid()); err != nil {
+		t.Errorf("valid request rejected: %v", err)
+	}
+}
+
+// bindAs decodes body into a new T the way the handlers do.
+func bindAs[T any](body string) error {
+	var req T
+	return binding.JSON.BindBody([]byte(body), &req)
+}
+
+func TestRequestTypes(t *testing.T) {
+	if err := Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	type rejection struct{ Field, Rule string }
+	tests := []struct {
+		name string
+		bind func(string) error
+		body string
+		want []rejection
+	}{
+		{"create valid", bindAs[models.ProductCreateRequest], `{"name":"Shirt","price":10}`, nil},
+		{"create without name", bindAs[models.ProductCreateRequest], `{"price":10}`,
+			[]rejection{{"name", "required"}}},
+		{"create blank name", bindAs[models.ProductCreateRequest], `{"name":"  ","price":10}`,
+			[]rejection{{"name", "notblank"}}},
+		{"create without price", bindAs[models.ProductCreateRequest], `{"name":"Shirt"}`,
+			[]rejection{{"price", "required"}}},
+		{"create fractional cents", bindAs[models.ProductCreateRequest], `{"name":"Shirt","price":1.005}`,
+			[]rejection{{"price", "money"}}},
+		{"create negative quantity", bindAs[models.ProductCreateRequest], `{"name":"Shirt","price":10,"quantity":-1}`,
+			[]rejection{{"quantity", "gte"}}},
+		{"create duplicate options", bindAs[models.ProductCreateRequest], `{"name":"Shirt","price":10,"options":["size","size"]}`,
+			[]rejection{{"options", "unique"}}},
+		{"create blank option", bindAs[models.ProductCreateRequest], `{"name":"Shirt","price":10,"options":["size"," "]}`,
+			[]rejection{{"options[1]", "notblank"}}},
+		{"create bad category", bindAs[models.ProductCreateRequest], `{"name":"Shirt","price":10,"category_ids":[1,0]}`,
+			[]rejection{{"category_ids[1]", "gt"}}},
+		{"create negative threshold", bindAs[models.ProductCreateRequest], `{"name":"Shirt","price":10,"low_stock_threshold":-1}`,
+			[]rejection{{"low_stock_threshold", "gte"}}},
+
+		{"replace valid", bindAs[models.ProductReplaceRequest], `{"name":"Shirt","price":10,"quantity":0}`, nil},
+		{"replace without quantity", bindAs[models.ProductReplaceRequest], `{"name":"Shirt","price":10}`,
+			[]rejection{{"quantity", "required"}}},
+		{"replace negative quantity", bindAs[models.ProductReplaceRequest], `{"name":"Shirt","price":10,"quantity":-1}`,
+			[]rejection{{"quantity", "gte"}}},
+		{"replace blank sku", bindAs[models.ProductReplaceRequest], `{"sku":" ","name":"Shirt","price":10,"quantity":1}`,
+			[]rejection{{"sku", "notblank"}}},
+		{"replace empty", bindAs[models.ProductReplaceRequest], `{}`,
+			[]rejection{{"name", "required"}, {"price", "required"}, {"quantity", "required"}}},
+
+		{"transaction valid", bindAs[models.TransactionRequest], `{"product_id":1,"quantity":1}`, nil},
+		{"transaction zero quantity", bindAs[models.TransactionRequest], `{"product_id":1,"quantity":0}`,
+			[]rejection{{"quantity", "required"}}},
+		{"transaction negative quantity", bindAs[models.TransactionRequest], `{"product_id":1,"quantity":-2}`,
+			[]rejection{{"quantity", "gt"}}},
+		{"transaction negative product", bindAs[models.TransactionRequest], `{"product_id":-1,"quantity":1}`,
+			[]rejection{{"product_id", "gt"}}},
+		{"transaction zero variant", bindAs[models.TransactionRequest], `{"product_id":1,"variant_id":0,"quantity":1}`,
+			[]rejection{{"variant_id", "gt"}}},
+
+		{"register valid", bindAs[models.RegisterRequest], `{"name":"Ann","email":"ann@example.com","password":"Passw0rd"}`, nil},
+		{"register blank name", bindAs[models.RegisterRequest], `{"name":" ","email":"ann@example.com","password":"Passw0rd"}`,
+			[]rejection{{"name", "notblank"}}},
+		{"register bad email", bindAs[models.RegisterRequest], `{"name":"Ann","email":"ann","password":"Passw0rd"}`,
+			[]rejection{{"email", "email"}}},
+		{"register weak password", bindAs[models.RegisterRequest], `{"name":"Ann","email":"ann@example.com","password":"password"}`,
+			[]rejection{{"password", "password"}}},
+		{"register admin", bindAs[models.RegisterRequest], `{"name":"Ann","email":"ann@example.com","password":"Passw0rd","role":"admin"}`,
+			[]rejection{{"role", "oneof"}}},
+
+		{"login valid", bindAs[models.LoginRequest], `{"email":"ann@example.com","password":"x"}`, nil},
+		{"login without password", bindAs[models.LoginRequest], `{"email":"ann@example.com"}`,
+			[]rejection{{"password", "required"}}},
+		{"login bad email", bindAs[models.LoginRequest], `{"email":"ann","password":"x"}`,
+			[]rejection{{"email", "email"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bind(tt.body)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("valid request rejected: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("request passed validation")
+			}
+
+			var envelope apperror.Envelope
+			body, _ := json.Marshal(apperror.Validation(err).Envelope(""))
+			if err := json.Unmarshal(body, &envelope); err != nil {
+				t.Fatalf("decode %s: %v", body, err)
+			}
+			var got []rejection
+			for _, detail := range envelope.Error.Details {
+				got = append(got, rejection{detail.Field, detail.Rule})
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %s, want details %v", body, tt.want)
+			}
+		})
+	}
+}